@@ -0,0 +1,101 @@
+package zoon
+
+import (
+	"bytes"
+	"strings"
+)
+
+// Compact appends to dst the ZOON document or documents in src with
+// insignificant whitespace removed: padding between cells and header
+// entries collapses to one space, lines lose their indentation and blank
+// lines are dropped, with --- separating documents. Quoted values keep
+// their spaces. It undoes MarshalIndent and WithAlignedColumns, as
+// json.Compact undoes json.Indent. On error dst is left unchanged.
+func Compact(dst *bytes.Buffer, src []byte) error {
+	start := dst.Len()
+	d := NewDecoder(bytes.NewReader(src))
+	openLine := false
+	for docs := 0; ; docs++ {
+		doc, err := d.readDocument()
+		if err == nil && !hasContent(doc) {
+			return nil
+		}
+		if err == nil && docs > 0 {
+			if openLine {
+				dst.WriteByte('\n')
+			}
+			dst.WriteString("---\n")
+		}
+		if err == nil {
+			openLine, err = compactDocument(dst, doc)
+		}
+		if err != nil {
+			dst.Truncate(start)
+			return err
+		}
+	}
+}
+
+// compactDocument writes the compact form of doc, one document, to dst.
+// openLine reports that it ends without a newline, as an inline object
+// does.
+func compactDocument(dst *bytes.Buffer, doc []byte) (openLine bool, err error) {
+	if err := checkStructure(doc); err != nil {
+		return false, err
+	}
+	body := bytes.TrimSpace(skipComments(doc))
+	if body[0] != '#' && body[0] != '%' && body[0] != '{' && !hasMetadata(body) {
+		// Comments go on lines of their own ahead of the object.
+		for line := range strings.Lines(string(doc)) {
+			if line = strings.TrimSpace(line); isComment(line) {
+				dst.WriteString(line + "\n")
+			}
+		}
+		return true, compactInline(dst, string(body))
+	}
+
+	inRows := body[0] == '{'
+	for line := range strings.Lines(string(doc)) {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+			continue
+		case isComment(line):
+			dst.WriteString(line)
+		case inRows && line[0] == '{':
+			dst.WriteByte('{')
+			if err := compactInline(dst, line[1:len(line)-1]); err != nil {
+				return false, err
+			}
+			dst.WriteByte('}')
+		case inRows:
+			dst.WriteString(strings.Join(tokenizeRow(line, " "), " "))
+		case line[0] == '%':
+			dst.WriteString(strings.Join(strings.Fields(line), " "))
+		case line[0] == '#':
+			dst.WriteString("# " + strings.Join(splitHeader(line[1:]), " "))
+			inRows = true
+		default:
+			// A metadata line, whose value is free text.
+			dst.WriteString(line)
+		}
+		dst.WriteByte('\n')
+	}
+	return false, nil
+}
+
+// compactInline writes the key-value pairs of an inline object separated
+// by single spaces.
+func compactInline(dst *bytes.Buffer, data string) error {
+	pairs, err := (&inlineParser{input: data}).parse()
+	if err != nil {
+		return err
+	}
+	for i, p := range pairs {
+		if i > 0 {
+			dst.WriteByte(' ')
+		}
+		dst.WriteString(p.key + p.sep + p.value)
+	}
+	return nil
+}
@@ -3,52 +3,355 @@ package zoon
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"encoding"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"math"
+	"math/big"
 	"reflect"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 )
 
-func (d *Decoder) decode(v any) error {
+func (d *Decoder) decode(ctx context.Context, v any) error {
 	rv := reflect.ValueOf(v)
 	if rv.Kind() != reflect.Ptr || rv.IsNil() {
 		return fmt.Errorf("zoon: Unmarshal(non-pointer %v)", rv.Type())
 	}
+	return d.decodeValue(ctx, rv.Elem())
+}
+
+// decodeValue reads the input into target, which must be settable.
+func (d *Decoder) decodeValue(ctx context.Context, target reflect.Value) error {
+	if !target.CanSet() {
+		return fmt.Errorf("%w: cannot decode into unsettable %v", ErrUnsupportedType, target.Type())
+	}
+	rv := target.Addr()
 
-	data, err := io.ReadAll(d.r)
+	data, err := d.readDocument()
 	if err != nil {
 		return err
 	}
-	data = bytes.TrimSpace(data)
-	if len(data) == 0 {
-		return nil
+	data = bytes.TrimSpace(skipComments(data))
+	if len(data) == 0 && d.header == nil {
+		return io.EOF
+	}
+	if d.strict {
+		if err := validateUTF8(data); err != nil {
+			return err
+		}
 	}
 
-	// If starts with % or #, it's tabular with potential aliases
-	if data[0] == '#' || data[0] == '%' {
-		return d.decodeTabular(data, rv)
+	if d.header == nil {
+		d.metadata = nil
+	}
+
+	// If starts with % or #, it's tabular with potential aliases, which
+	// metadata lines may precede
+	if d.header != nil || data[0] == '#' || data[0] == '%' || hasMetadata(data) {
+		return d.decodeTabular(ctx, data, rv)
+	}
+	// A top-level inline object starts with a key, so a brace means object rows
+	if data[0] == '{' {
+		return d.decodeObjectRows(ctx, data, rv)
 	}
 	return d.decodeInline(string(data), rv)
 }
 
+func (d *Decoder) decodeObjectRows(ctx context.Context, data []byte, rv reflect.Value) error {
+	sliceVal := rv.Elem()
+	if sliceVal.Kind() != reflect.Slice {
+		return fmt.Errorf("zoon: object rows expect slice, got %v", sliceVal.Kind())
+	}
+	sliceVal.SetLen(0)
+
+	elemType := sliceVal.Type().Elem()
+	isPtr := false
+	if elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+		isPtr = true
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || isComment(line) {
+			continue
+		}
+		if !strings.HasPrefix(line, "{") || !strings.HasSuffix(line, "}") {
+			return fmt.Errorf("%w: expected {...} row, got %q", ErrInvalidFormat, line)
+		}
+
+		newPtr := reflect.New(elemType)
+		if err := d.decodeInline(line[1:len(line)-1], newPtr); err != nil {
+			return err
+		}
+		setRaw(newPtr.Elem(), line)
+		if isPtr {
+			sliceVal = reflect.Append(sliceVal, newPtr)
+		} else {
+			sliceVal = reflect.Append(sliceVal, newPtr.Elem())
+		}
+	}
+
+	rv.Elem().Set(sliceVal)
+	return nil
+}
+
+// isComment reports whether a trimmed line is a // comment, which may
+// appear anywhere between the lines of a document.
+func isComment(line string) bool {
+	return strings.HasPrefix(line, "//")
+}
+
+// skipComments drops the blank and comment lines at the start of data, so
+// the first remaining byte shows which form the document takes.
+func skipComments(data []byte) []byte {
+	for {
+		trimmed := bytes.TrimLeft(data, " \t\r\n")
+		if !bytes.HasPrefix(trimmed, []byte("//")) {
+			return trimmed
+		}
+		_, rest, ok := bytes.Cut(trimmed, []byte("\n"))
+		if !ok {
+			return nil
+		}
+		data = rest
+	}
+}
+
+// validateUTF8 reports the line and byte offset of the first invalid UTF-8
+// sequence in data.
+func validateUTF8(data []byte) error {
+	if utf8.Valid(data) {
+		return nil
+	}
+	line := 1
+	for i := 0; i < len(data); {
+		r, size := utf8.DecodeRune(data[i:])
+		if r == utf8.RuneError && size == 1 {
+			return fmt.Errorf("%w: invalid UTF-8 at line %d, byte offset %d", ErrInvalidFormat, line, i)
+		}
+		if r == '\n' {
+			line++
+		}
+		i += size
+	}
+	return nil
+}
+
+// headerField is a column or hoisted constant of a table header as the
+// decoder reads it. ColumnDef is its public form.
 type headerField struct {
-	name    string
-	typ     string
-	val     string
-	indexed bool
-	options []string
+	Name          string   // full dotted name, with aliases expanded
+	Type          string   // type code, such as i, f, i+ or i+0; s for enums
+	Indexed       bool     // enum cells hold option indexes
+	Options       []string // enum options, in header order
+	IsConstant    bool     // hoisted @name constant rather than a column
+	ConstantValue string   // a constant's value as written
+
+	dst reflect.Type // destination field type, when known
 }
 
-func (d *Decoder) decodeTabular(data []byte, rv reflect.Value) error {
-	scanner := bufio.NewScanner(bytes.NewReader(data))
+// tableHeader is a parsed table header: its hoisted constants, its columns
+// and the +N row count, or -1 when none is given.
+type tableHeader struct {
+	constants []headerField
+	columns   []headerField
+	rows      int
+	aliases   map[string]string // alias to prefix, from % lines
+	metadata  map[string]string // key:value lines before the header
+}
+
+// defs returns the header's constants and columns as ColumnDefs, in header
+// order.
+func (h *tableHeader) defs() (constants, columns []ColumnDef) {
+	for _, f := range h.constants {
+		constants = append(constants, ColumnDef{Name: f.Name, TypeCode: f.Type, IsConstant: true, Value: f.ConstantValue})
+	}
+	for _, f := range h.columns {
+		def := ColumnDef{Name: f.Name, TypeCode: f.Type, Indexed: f.Indexed, Options: f.Options}
+		if start, ok := autoIncStart(f.Type); ok {
+			def.TypeCode, def.IsAutoInc = "i", true
+			if start != 1 {
+				def.Value = strconv.FormatInt(start, 10)
+			}
+		}
+		columns = append(columns, def)
+	}
+	return constants, columns
+}
+
+// metadataLine splits a key:value line written ahead of a table header,
+// such as "source: crm export". The key holds no spaces, and a quoted
+// value is unquoted.
+func metadataLine(line string) (key, val string, ok bool) {
+	key, val, ok = strings.Cut(line, ":")
+	if !ok || key == "" || strings.ContainsAny(key, " \t=") {
+		return "", "", false
+	}
+	val = strings.TrimSpace(val)
+	if isQuoted(val) {
+		val = unquote(val)
+	}
+	return key, val, true
+}
+
+// hasMetadata reports whether doc opens with metadata lines followed by a
+// table header, rather than being an inline object.
+func hasMetadata(doc []byte) bool {
+	for line := range strings.Lines(string(doc)) {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+		case line[0] == '#' || line[0] == '%':
+			return true
+		default:
+			if _, _, ok := metadataLine(line); !ok {
+				return false
+			}
+		}
+	}
+	return false
+}
+
+// checkConflicts reports a field set both by a hoisted constant and by a
+// column, which lenient decoding resolves in favour of the column.
+func (h *tableHeader) checkConflicts() error {
+	for _, c := range h.constants {
+		for _, col := range h.columns {
+			if c.Name == col.Name {
+				return fmt.Errorf("%w: field %s is both a constant and a column", ErrInvalidFormat, c.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// lineScanner is the part of bufio.Scanner that readHeader uses.
+type lineScanner interface {
+	Scan() bool
+	Text() string
+	Err() error
+}
+
+// readerLines feeds readHeader one line at a time from a bufio.Reader, so
+// nothing past the header is consumed.
+type readerLines struct {
+	r    *bufio.Reader
+	line string
+	err  error
+}
+
+func (l *readerLines) Scan() bool {
+	if l.err != nil {
+		return false
+	}
+	line, err := l.r.ReadString('\n')
+	if err != nil && err != io.EOF {
+		l.err = err
+		return false
+	}
+	if line == "" {
+		return false
+	}
+	l.line = strings.TrimRight(line, "\r\n")
+	return true
+}
+
+func (l *readerLines) Text() string { return l.line }
+
+func (l *readerLines) Err() error { return l.err }
+
+// reader returns d's input as a bufio.Reader, wrapping it on first use so
+// that reads past the current document stay buffered for the next.
+func (d *Decoder) reader() *bufio.Reader {
+	br, ok := d.r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(d.r)
+		d.r = br
+	}
+	return br
+}
+
+// readDocument returns the next document of the input, the lines up to a
+// --- separator line, two consecutive blank lines or the end of input. An
+// inline object, which takes a single line, also ends at one blank line.
+// Separators before any content are skipped. A document read ahead by More
+// is returned first.
+func (d *Decoder) readDocument() ([]byte, error) {
+	if d.peeked {
+		d.peeked = false
+		return d.next, d.nextErr
+	}
 
+	br := d.reader()
+	var buf bytes.Buffer
+	blanks := 0
+	inline := false
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		switch trimmed := strings.TrimSpace(line); {
+		case trimmed == "---":
+			if hasContent(buf.Bytes()) {
+				return buf.Bytes(), nil
+			}
+			blanks = 0
+		case trimmed == "":
+			blanks++
+			if (blanks >= 2 || inline) && hasContent(buf.Bytes()) {
+				return buf.Bytes(), nil
+			}
+			buf.WriteString(line)
+		default:
+			if !hasContent(buf.Bytes()) && !isComment(trimmed) {
+				inline = !strings.ContainsAny(trimmed[:1], "#%{")
+			}
+			blanks = 0
+			buf.WriteString(line)
+		}
+		if err == io.EOF {
+			return buf.Bytes(), nil
+		}
+	}
+}
+
+// hasContent reports whether doc holds anything besides blank lines and
+// comments.
+func hasContent(doc []byte) bool {
+	return len(bytes.TrimSpace(skipComments(doc))) > 0
+}
+
+// takeHeader returns the header read by DecodeHeader, if any, clearing it so
+// it serves a single decode.
+func (d *Decoder) takeHeader() *tableHeader {
+	hdr := d.header
+	d.header = nil
+	return hdr
+}
+
+// readHeader consumes the alias and # header lines at the start of a table.
+func readHeader(scanner lineScanner) (*tableHeader, error) {
 	aliases := make(map[string]string)
+	var metadata map[string]string
 	var headerLine string
 
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
-		if line == "" {
+		if line == "" || isComment(line) {
 			continue
 		}
 
@@ -67,26 +370,30 @@ func (d *Decoder) decodeTabular(data []byte, rv reflect.Value) error {
 		} else if strings.HasPrefix(line, "#") {
 			headerLine = line
 			break
+		} else if key, val, ok := metadataLine(line); ok {
+			if metadata == nil {
+				metadata = make(map[string]string)
+			}
+			metadata[key] = val
 		} else {
 			// Should not happen if compliant, but maybe direct data?
 			// Assume implicit header not supported for now.
-			return fmt.Errorf("zoon: invalid format, expected header")
+			return nil, fmt.Errorf("zoon: invalid format, expected header")
 		}
 	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
 
 	if headerLine == "" {
-		return fmt.Errorf("zoon: missing header")
+		return nil, fmt.Errorf("zoon: missing header")
 	}
 
-	headerParts := strings.Fields(headerLine[2:])
-	var headers []headerField
-	var constants []headerField // using same struct for convenience
-	explicitRows := -1
-
-	for _, part := range headerParts {
+	hdr := &tableHeader{rows: -1, aliases: aliases, metadata: metadata}
+	for _, part := range splitHeader(strings.TrimPrefix(headerLine, "#")) {
 		if strings.HasPrefix(part, "+") {
 			if n, err := strconv.Atoi(part[1:]); err == nil {
-				explicitRows = n
+				hdr.rows = n
 			}
 			continue
 		}
@@ -97,68 +404,192 @@ func (d *Decoder) decodeTabular(data []byte, rv reflect.Value) error {
 			part = part[1:]
 		}
 
-		// Expand alias
-		// Split name from type
-		sepIdx := strings.IndexAny(part, ":=!")
-		if sepIdx == -1 {
+		// Split name from type, then expand alias
+		rawName, typVal, ok := splitColumn(part)
+		if !ok {
 			continue
 		}
-
-		name := part[:sepIdx]
-		typVal := part[sepIdx:] // includes separator
-
-		// Unalias name
-		if strings.HasPrefix(name, "%") {
-			dotIdx := strings.Index(name, ".")
-			if dotIdx != -1 {
-				aName := name[1:dotIdx]
-				suffix := name[dotIdx+1:]
-				if prefix, ok := aliases[aName]; ok {
-					name = prefix + "." + suffix
-				}
-			} else {
-				if prefix, ok := aliases[name[1:]]; ok {
-					name = prefix
-				}
-			}
-		}
+		name := resolveAlias(rawName, aliases)
 
 		sep := typVal[0]
 		suffix := typVal[1:]
 
-		hf := headerField{name: name}
+		hf := headerField{Name: name, IsConstant: isConst}
 
 		if isConst {
-			hf.val = suffix
+			hf.ConstantValue = suffix
 			if sep == '=' {
-				hf.typ = "s"
-				hf.val = strings.ReplaceAll(hf.val, "_", " ")
+				hf.Type = "s"
+			} else if typ, val, ok := strings.Cut(suffix, "="); ok {
+				// @name:type=value, written with explicit types
+				hf.Type, hf.ConstantValue = typ, val
 			} else {
-				// :type or :value (inferred)
-				// If suffix is a type code like 'i' or 'b', then it's not a value (?)
-				// Actually constant syntax is @name=value or @name:value
-				// If value is simple, it's inferred.
-				hf.typ = "inferred"
+				// @name:value, type inferred from the value
+				hf.Type = ""
 			}
-			constants = append(constants, hf)
+			hdr.constants = append(hdr.constants, hf)
 		} else {
 			if sep == '=' {
-				hf.typ = "s"
-				hf.options = strings.Split(suffix, "|")
+				hf.Type = "s"
+				hf.Options = strings.Split(suffix, "|")
 			} else if sep == '!' {
-				hf.typ = "s"
-				hf.indexed = true
-				hf.options = strings.Split(suffix, "|")
+				hf.Type = "s"
+				hf.Indexed = true
+				hf.Options = strings.Split(suffix, "|")
+			} else {
+				hf.Type = suffix
+			}
+			hdr.columns = append(hdr.columns, hf)
+		}
+	}
+	return hdr, nil
+}
+
+// rowDecoder decodes the data rows of one table into values of elemType,
+// carrying the row count for i+ columns and i^ running sums from row to
+// row.
+type rowDecoder struct {
+	d         *Decoder
+	hdr       *tableHeader
+	elemType  reflect.Type
+	rowNum    int64 // rows decoded so far
+	deltaSums []int64
+	defaults  []fieldDefault // for fields no column or constant names
+}
+
+func newRowDecoder(d *Decoder, hdr *tableHeader, elemType reflect.Type) *rowDecoder {
+	for i := range hdr.columns {
+		hdr.columns[i].dst = typeAtPath(elemType, hdr.columns[i].Name)
+	}
+	var named []string
+	for _, c := range hdr.constants {
+		named = append(named, c.Name)
+	}
+	for _, c := range hdr.columns {
+		named = append(named, c.Name)
+	}
+	return &rowDecoder{
+		d:         d,
+		hdr:       hdr,
+		elemType:  elemType,
+		deltaSums: make([]int64, len(hdr.columns)),
+		defaults:  fieldDefaults(elemType, named),
+	}
+}
+
+// decode returns the element held by a row's values, read from the source
+// line raw. null reports a ~ in a primitive list, which a pointer element
+// stores as nil.
+func (r *rowDecoder) decode(vals []string, raw string) (elem reflect.Value, null bool, err error) {
+	d := r.d
+	newElem := reflect.New(r.elemType).Elem()
+	row := r.rowNum
+	r.rowNum++
+
+	if err := d.setDefaults(newElem, r.defaults); err != nil {
+		return newElem, false, err
+	}
+
+	// Apply constants
+	for _, c := range r.hdr.constants {
+		valStr := c.ConstantValue
+		// Infer type logic if needed, setField handles basic types
+		if err := d.setDeepField(newElem, c.Name, c.Type, valStr); err != nil {
+			return newElem, false, err
+		}
+	}
+
+	valIdx := 0
+	nullElem := false // a ~ in a primitive list
+	for hi, h := range r.hdr.columns {
+		var valStr string
+
+		if start, ok := autoIncStart(h.Type); ok {
+			valStr = strconv.FormatInt(start+row, 10)
+		} else {
+			if valIdx >= len(vals) {
+				// Missing value? Null?
+				valStr = "~"
 			} else {
-				hf.typ = suffix
+				valStr = vals[valIdx]
+				valIdx++
+			}
+		}
+		if d.nullSymbol != "" && valStr == d.nullSymbol {
+			valStr = "~"
+		}
+
+		if valStr == "~" {
+			// Map rows leave the key out, as the encoder writes ~ for keys
+			// a row lacks.
+			if h.Name == "" {
+				nullElem = true
 			}
-			headers = append(headers, hf)
+			continue
+		}
+
+		typ := h.Type
+		if typ == "i^" {
+			delta, err := strconv.ParseInt(valStr, 10, 64)
+			if err != nil {
+				return newElem, false, fmt.Errorf("%w: bad delta %q in column %s", ErrInvalidFormat, valStr, h.Name)
+			}
+			r.deltaSums[hi] += delta
+			valStr, typ = strconv.FormatInt(r.deltaSums[hi], 10), "i"
+		}
+		if len(h.Options) > 0 {
+			if h.Indexed {
+				// A bad index names no value to pass through, so it is
+				// an error even when not strict.
+				idx, err := strconv.Atoi(valStr)
+				if err != nil {
+					return newElem, false, fmt.Errorf("%w: enum index %q is not a number for column %s", ErrInvalidFormat, valStr, h.Name)
+				}
+				if idx < 0 || idx >= len(h.Options) {
+					return newElem, false, fmt.Errorf("%w: enum index %q out of range for column %s with %d options", ErrInvalidFormat, valStr, h.Name, len(h.Options))
+				}
+				// The index only picks the label; the label is decoded like
+				// any other cell, so int-backed enums need a numeric label,
+				// an Unmarshaler or a registered decode func.
+				valStr = h.Options[idx]
+			} else if (d.strict || d.enumValidation) && !slices.Contains(h.Options, valStr) {
+				return newElem, false, fmt.Errorf("%w: invalid enum value %q for column %s, want one of %s", ErrInvalidFormat, valStr, h.Name, strings.Join(h.Options, "|"))
+			}
+			typ = enumValueType(h.dst)
+		}
+
+		if err := d.setDeepField(newElem, h.Name, typ, valStr); err != nil {
+			return newElem, false, err
+		}
+	}
+	if d.strictFields || d.requiredFields {
+		if err := checkRequired(newElem); err != nil {
+			return newElem, false, err
+		}
+	}
+	setRaw(newElem, raw)
+	return newElem, nullElem, nil
+}
+
+func (d *Decoder) decodeTabular(ctx context.Context, data []byte, rv reflect.Value) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	hdr := d.takeHeader()
+	if hdr == nil {
+		var err error
+		if hdr, err = readHeader(scanner); err != nil {
+			return err
 		}
 	}
+	d.metadata = hdr.metadata
 
 	sliceVal := rv.Elem()
 	if sliceVal.Kind() == reflect.Slice {
 		sliceVal.SetLen(0)
+		// A +N row count sizes the slice up front, so appending the rows
+		// does not reallocate it as it grows.
+		if hdr.rows > sliceVal.Cap() {
+			sliceVal.Set(reflect.MakeSlice(sliceVal.Type(), 0, hdr.rows))
+		}
 	} else if sliceVal.Kind() != reflect.Array {
 		return fmt.Errorf("zoon: tabular format expects slice, got %v", sliceVal.Kind())
 	}
@@ -170,53 +601,22 @@ func (d *Decoder) decodeTabular(data []byte, rv reflect.Value) error {
 		isPtr = true
 	}
 
-	autoIncID := 0
-
-	processRow := func(vals []string) error {
-		newElem := reflect.New(elemType).Elem()
-
-		// Apply constants
-		for _, c := range constants {
-			valStr := c.val
-			// Infer type logic if needed, setField handles basic types
-			if err := setDeepField(newElem, c.name, "auto", valStr); err != nil {
-				return err
-			}
+	if d.strict {
+		if err := hdr.checkConflicts(); err != nil {
+			return err
 		}
+	}
 
-		valIdx := 0
-		for _, h := range headers {
-			var valStr string
-
-			if h.typ == "i+" {
-				autoIncID++
-				valStr = fmt.Sprintf("%d", autoIncID)
-			} else {
-				if valIdx >= len(vals) {
-					// Missing value? Null?
-					valStr = "~"
-				} else {
-					valStr = vals[valIdx]
-					valIdx++
-				}
-			}
-
-			if valStr == "~" {
-				continue
-			}
-
-			if h.indexed && len(h.options) > 0 {
-				if idx, err := strconv.Atoi(valStr); err == nil && idx >= 0 && idx < len(h.options) {
-					valStr = h.options[idx]
-				}
-			}
-
-			if err := setDeepField(newElem, h.name, h.typ, valStr); err != nil {
-				return err
-			}
+	rd := newRowDecoder(d, hdr, elemType)
+	processRow := func(vals []string, raw string) error {
+		newElem, nullElem, err := rd.decode(vals, raw)
+		if err != nil {
+			return err
 		}
 
-		if isPtr {
+		if isPtr && nullElem {
+			sliceVal.Set(reflect.Append(sliceVal, reflect.Zero(sliceVal.Type().Elem())))
+		} else if isPtr {
 			newPtr := reflect.New(elemType)
 			newPtr.Elem().Set(newElem)
 			sliceVal.Set(reflect.Append(sliceVal, newPtr))
@@ -226,28 +626,50 @@ func (d *Decoder) decodeTabular(data []byte, rv reflect.Value) error {
 		return nil
 	}
 
-	if explicitRows > 0 {
-		for i := 0; i < explicitRows; i++ {
-			if err := processRow(nil); err != nil {
+	// In skip mode a failing row is dropped and its error collected, so the
+	// rows that did decode are still returned.
+	var rowErrs []error
+	rowNum := 0
+	handleRow := func(vals []string, raw string) error {
+		rowNum++
+		if err := processRow(vals, raw); err != nil {
+			err = fmt.Errorf("%w in row %d", err, rowNum)
+			if !d.skipBadRows {
+				return err
+			}
+			rowErrs = append(rowErrs, err)
+		}
+		return nil
+	}
+
+	if hdr.rows > 0 {
+		for i := 0; i < hdr.rows; i++ {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := handleRow(nil, ""); err != nil {
 				return err
 			}
 		}
 	}
 
 	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		line := strings.TrimSpace(scanner.Text())
-		if line == "" {
+		if line == "" || isComment(line) {
 			continue
 		}
 
-		vals := tokenizeRow(line)
-		if err := processRow(vals); err != nil {
+		vals := tokenizeRow(line, string(d.separator()))
+		if err := handleRow(vals, line); err != nil {
 			return err
 		}
 	}
 
 	rv.Elem().Set(sliceVal)
-	return nil
+	return errors.Join(rowErrs...)
 }
 
 func (d *Decoder) decodeInline(data string, rv reflect.Value) error {
@@ -265,117 +687,400 @@ func (d *Decoder) decodeInline(data string, rv reflect.Value) error {
 		return err
 	}
 
+	named := make([]string, len(pairs))
+	for i, p := range pairs {
+		named[i] = p.key
+	}
+	if err := d.setDefaults(target, fieldDefaults(target.Type(), named)); err != nil {
+		return err
+	}
+
 	for _, p := range pairs {
-		val := p.value
+		typ := "auto"
 		if p.sep == "=" {
-			val = strings.ReplaceAll(val, "_", " ")
-		} else if strings.HasPrefix(val, "{") && strings.HasSuffix(val, "}") {
-
+			typ = "s"
 		}
 
-		if err := setDeepField(target, p.key, "auto", val); err != nil {
+		if err := d.setDeepField(target, p.key, typ, p.value); err != nil {
 			return err
 		}
 	}
+	if d.strictFields || d.requiredFields {
+		if err := checkRequired(target); err != nil {
+			return err
+		}
+	}
+	setRaw(target, data)
 
 	return nil
 }
 
-type inlinePair struct {
-	key, sep, value string
-}
-
-type inlineParser struct {
-	input string
-	pos   int
+// fieldDefault is the value of a field tagged "default:", by its path.
+type fieldDefault struct {
+	path, value string
 }
 
-func (p *inlineParser) parse() ([]inlinePair, error) {
-	var pairs []inlinePair
-	for p.pos < len(p.input) {
-		p.skipSpace()
-		if p.pos >= len(p.input) {
-			break
+// fieldDefaults returns the defaults of t's fields, and of the structs
+// nested in it, that the input names nowhere: neither the field itself nor
+// a parent written as a whole is among named.
+func fieldDefaults(t reflect.Type, named []string) []fieldDefault {
+	var defaults []fieldDefault
+	var walk func(t reflect.Type, prefix string)
+	walk = func(t reflect.Type, prefix string) {
+		if t.Kind() != reflect.Struct || t == timeType || isBigType(t) || isMarshaler(t) {
+			return
 		}
-
-		keyStart := p.pos
-		for p.pos < len(p.input) && p.input[p.pos] != ':' && p.input[p.pos] != '=' && p.input[p.pos] != ' ' {
-			p.pos++
+		for _, sf := range structFields(t) {
+			path := prefix + sf.opts.name
+			if slices.ContainsFunc(named, func(n string) bool {
+				return n == path || strings.HasPrefix(path, n+".")
+			}) {
+				continue
+			}
+			if sf.opts.def != "" {
+				defaults = append(defaults, fieldDefault{path, sf.opts.def})
+				continue
+			}
+			walk(t.FieldByIndex(sf.index).Type, path+".")
 		}
-		key := p.input[keyStart:p.pos]
+	}
+	walk(t, "")
+	return defaults
+}
 
-		if p.pos >= len(p.input) {
-			return nil, fmt.Errorf("unexpected end after key %s", key)
+// setDefaults decodes each default into its field of v, inferring its type
+// as for an untyped constant.
+func (d *Decoder) setDefaults(v reflect.Value, defaults []fieldDefault) error {
+	for _, f := range defaults {
+		if err := d.setDeepField(v, f.path, "", f.value); err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
-		sep := string(p.input[p.pos])
-		p.pos++
-
-		valStart := p.pos
-		if p.pos < len(p.input) && p.input[p.pos] == '{' {
-			depth := 1
-			p.pos++
-			for p.pos < len(p.input) && depth > 0 {
-				if p.input[p.pos] == '{' {
-					depth++
-				}
-				if p.input[p.pos] == '}' {
-					depth--
-				}
-				p.pos++
+// checkRequired returns an error naming the fields of v, and of the structs
+// nested in it, that are tagged required but hold their zero value, having
+// been left out or given ~.
+func checkRequired(v reflect.Value) error {
+	var missing []string
+	var walk func(v reflect.Value, prefix string)
+	walk = func(v reflect.Value, prefix string) {
+		if v.Kind() != reflect.Struct || v.Type() == timeType || isBigType(v.Type()) {
+			return
+		}
+		for _, sf := range structFields(v.Type()) {
+			name := prefix + sf.opts.name
+			f, ok := fieldByIndex(v, sf.index)
+			if sf.opts.required && (!ok || f.IsZero()) {
+				missing = append(missing, name)
+				continue
 			}
-		} else {
-			for p.pos < len(p.input) && p.input[p.pos] != ' ' {
-				p.pos++
+			if ok {
+				walk(reflect.Indirect(f), name+".")
 			}
 		}
-		val := p.input[valStart:p.pos]
-
-		pairs = append(pairs, inlinePair{key, sep, val})
 	}
-	return pairs, nil
-}
-
-func (p *inlineParser) skipSpace() {
-	for p.pos < len(p.input) && (p.input[p.pos] == ' ' || p.input[p.pos] == '\n') {
-		p.pos++
+	walk(v, "")
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: missing required fields %s", ErrInvalidFormat, strings.Join(missing, ", "))
 	}
+	return nil
 }
 
-func tokenizeRow(line string) []string {
-	var tokens []string
+// setRaw stores raw, the source text v was decoded from, in v's field
+// tagged ",raw", if it has one.
+func setRaw(v reflect.Value, raw string) {
+	if v.Kind() != reflect.Struct {
+		return
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if !isRawField(t.Field(i)) || !t.Field(i).IsExported() {
+			continue
+		}
+		f := v.Field(i)
+		if f.Kind() == reflect.String {
+			f.SetString(raw)
+		} else if isByteSlice(f.Type()) {
+			f.SetBytes([]byte(raw))
+		}
+		return
+	}
+}
+
+// splitHeader splits a header line into its space-separated entries,
+// keeping spaces inside quoted column names.
+func splitHeader(line string) []string {
+	var parts []string
+	start, inQuote := -1, false
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case inQuote && c == '\\':
+			i++
+		case c == '"':
+			inQuote = !inQuote
+		case c == ' ' && !inQuote:
+			if start >= 0 {
+				parts = append(parts, line[start:i])
+				start = -1
+			}
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		parts = append(parts, line[start:])
+	}
+	return parts
+}
+
+// splitColumn splits a header entry into its column name, unquoted if
+// needed, and the remainder starting at the : = or ! separator.
+func splitColumn(part string) (name, rest string, ok bool) {
+	if strings.HasPrefix(part, `"`) {
+		for i := 1; i < len(part); i++ {
+			if part[i] == '\\' {
+				i++
+			} else if part[i] == '"' {
+				rest = part[i+1:]
+				if rest == "" || !strings.ContainsRune(":=!", rune(rest[0])) {
+					return "", "", false
+				}
+				return unquote(part[:i+1]), rest, true
+			}
+		}
+		return "", "", false
+	}
+	sepIdx := strings.IndexAny(part, ":=!")
+	if sepIdx == -1 {
+		return "", "", false
+	}
+	return part[:sepIdx], part[sepIdx:], true
+}
+
+// resolveAlias expands a %alias or %alias.suffix column name. It is the
+// inverse of applyAlias; a bare %alias names the aliased prefix itself.
+func resolveAlias(name string, aliases map[string]string) string {
+	if !strings.HasPrefix(name, "%") {
+		return name
+	}
+	aName, suffix, hasSuffix := strings.Cut(name[1:], ".")
+	prefix, ok := aliases[aName]
+	if !ok {
+		return name
+	}
+	if hasSuffix {
+		return prefix + "." + suffix
+	}
+	return prefix
+}
+
+type inlinePair struct {
+	key, sep, value string
+}
+
+type inlineParser struct {
+	input string
+	pos   int
+}
+
+func (p *inlineParser) parse() ([]inlinePair, error) {
+	var pairs []inlinePair
+	for p.pos < len(p.input) {
+		p.skipSpace()
+		if p.pos >= len(p.input) {
+			break
+		}
+
+		keyStart := p.pos
+		for p.pos < len(p.input) && p.input[p.pos] != ':' && p.input[p.pos] != '=' && p.input[p.pos] != ' ' {
+			p.pos++
+		}
+		key := p.input[keyStart:p.pos]
+
+		if p.pos >= len(p.input) {
+			return nil, fmt.Errorf("%w: unexpected end after key %s", ErrInvalidFormat, key)
+		}
+		if key == "" {
+			return nil, fmt.Errorf("%w: missing key before %q", ErrInvalidFormat, p.input[p.pos:])
+		}
+		if p.input[p.pos] == ' ' {
+			return nil, fmt.Errorf("%w: missing : or = after key %s", ErrInvalidFormat, key)
+		}
+
+		sep := string(p.input[p.pos])
+		p.pos++
+
+		valStart := p.pos
+		closed := true
+		if p.pos < len(p.input) && p.input[p.pos] == '"' {
+			p.pos = quotedEnd(p.input, p.pos)
+			closed = p.pos-valStart >= 2 && p.input[p.pos-1] == '"'
+		} else if p.pos < len(p.input) && (p.input[p.pos] == '{' || p.input[p.pos] == '[') {
+			p.pos, closed = bracedEnd(p.input, p.pos)
+		} else {
+			for p.pos < len(p.input) && p.input[p.pos] != ' ' {
+				p.pos++
+			}
+		}
+		val := p.input[valStart:p.pos]
+		if val == "" {
+			return nil, fmt.Errorf("%w: missing value after %s%s", ErrInvalidFormat, key, sep)
+		}
+		if !closed {
+			return nil, fmt.Errorf("%w: unterminated value %q for key %s", ErrInvalidFormat, val, key)
+		}
+
+		pairs = append(pairs, inlinePair{key, sep, val})
+	}
+	return pairs, nil
+}
+
+// quotedEnd returns the index just past the quoted token starting at s[i],
+// honoring backslash escapes.
+func quotedEnd(s string, i int) int {
+	for i++; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+// bracedEnd returns the index just past the {...} object or [...] list
+// starting at s[i], and whether it is closed before s ends. Brackets inside
+// quoted values don't count towards nesting.
+func bracedEnd(s string, i int) (end int, closed bool) {
+	depth := 0
+	for i < len(s) {
+		switch s[i] {
+		case '"':
+			i = quotedEnd(s, i)
+			continue
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+			if depth == 0 {
+				return i + 1, true
+			}
+		}
+		i++
+	}
+	return len(s), false
+}
+
+// checkStructure parses doc, one document, as far as it can without
+// decoding any values: the header of a table and the cells of its rows, or
+// the pairs of an inline object or of each object row.
+func checkStructure(doc []byte) error {
+	data := bytes.TrimSpace(skipComments(doc))
+	if len(data) == 0 {
+		return io.EOF
+	}
+
+	if data[0] == '#' || data[0] == '%' || hasMetadata(data) {
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		hdr, err := readHeader(scanner)
+		if err != nil {
+			return err
+		}
+		if hdr.rows >= 0 {
+			// Rows implied by +N take no lines.
+			return nil
+		}
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || isComment(line) {
+				continue
+			}
+			for _, cell := range tokenizeRow(line, " \t") {
+				if err := checkCell(cell); err != nil {
+					return err
+				}
+			}
+		}
+		return scanner.Err()
+	}
+
+	if data[0] == '{' {
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || isComment(line) {
+				continue
+			}
+			if line[0] != '{' || checkCell(line) != nil {
+				return fmt.Errorf("%w: expected {...} row, got %q", ErrInvalidFormat, line)
+			}
+			if _, err := (&inlineParser{input: line[1 : len(line)-1]}).parse(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	_, err := (&inlineParser{input: string(data)}).parse()
+	return err
+}
+
+// checkCell returns an error for a row cell with an unterminated quote or
+// unbalanced braces or brackets.
+func checkCell(cell string) error {
+	switch cell[0] {
+	case '"':
+		if len(cell) < 2 || quotedEnd(cell, 0) != len(cell) || cell[len(cell)-1] != '"' {
+			return fmt.Errorf("%w: unterminated quoted value %s", ErrInvalidFormat, cell)
+		}
+	case '{', '[':
+		if end, closed := bracedEnd(cell, 0); !closed || end != len(cell) {
+			return fmt.Errorf("%w: unbalanced value %s", ErrInvalidFormat, cell)
+		}
+	default:
+		// Writers quote values holding braces.
+		if strings.ContainsAny(cell, "{}") {
+			return fmt.Errorf("%w: stray brace in value %s", ErrInvalidFormat, cell)
+		}
+	}
+	return nil
+}
+
+func (p *inlineParser) skipSpace() {
+	for p.pos < len(p.input) && (p.input[p.pos] == ' ' || p.input[p.pos] == '\n') {
+		p.pos++
+	}
+}
+
+// tokenizeRow splits a row into its cells, which are separated by any of
+// the bytes in seps. Quoted cells and {...} or [...] cells may hold
+// separators themselves.
+func tokenizeRow(line, seps string) []string {
+	var tokens []string
 	i := 0
 	for i < len(line) {
-		for i < len(line) && line[i] == ' ' {
+		for i < len(line) && strings.IndexByte(seps, line[i]) >= 0 {
 			i++
 		}
 		if i >= len(line) {
 			break
 		}
 		if line[i] == '"' {
-			end := i + 1
-			for end < len(line) {
-				if line[end] == '\\' && end+1 < len(line) {
-					end += 2
-				} else if line[end] == '"' {
-					end++
-					break
-				} else {
-					end++
-				}
-			}
-			tokens = append(tokens, line[i+1:end-1])
+			end := quotedEnd(line, i)
+			tokens = append(tokens, line[i:end])
+			i = end
+		} else if line[i] == '{' || line[i] == '[' {
+			end, _ := bracedEnd(line, i)
+			tokens = append(tokens, line[i:end])
 			i = end
-		} else if line[i] == '[' {
-			end := i + 1
-			for end < len(line) && line[end] != ']' {
-				end++
-			}
-			tokens = append(tokens, line[i:end+1])
-			i = end + 1
 		} else {
 			end := i
-			for end < len(line) && line[end] != ' ' {
+			for end < len(line) && strings.IndexByte(seps, line[end]) < 0 {
 				end++
 			}
 			tokens = append(tokens, line[i:end])
@@ -385,7 +1090,7 @@ func tokenizeRow(line string) []string {
 	return tokens
 }
 
-func setDeepField(dest reflect.Value, path, typ, valStr string) error {
+func (d *Decoder) setDeepField(dest reflect.Value, path, typ, valStr string) error {
 	parts := strings.Split(path, ".")
 	current := dest
 
@@ -393,75 +1098,63 @@ func setDeepField(dest reflect.Value, path, typ, valStr string) error {
 		current = deref(current)
 
 		if i == len(parts)-1 {
-			// Set value
-			return setField(current, part, typ, valStr)
+			if i > 0 && current.Kind() == reflect.Struct && !findField(current, part).IsValid() {
+				return d.unknownField(deref(dest), path)
+			}
+			return d.setField(current, part, typ, valStr)
 		}
 
 		// Navigate deeper
 		if current.Kind() == reflect.Map {
+			if current.Type().Key().Kind() != reflect.String {
+				return fmt.Errorf("zoon: map key must be string for path %s", path)
+			}
 			if current.IsNil() {
 				current.Set(reflect.MakeMap(current.Type()))
 			}
-			// Maps are tricky because we need to get the element to set it?
-			// Or create a new temp value, set it, then put it back.
-			// Currently setField handles map insertion.
-			// But here we're mid-path.
-
-			// Warning: Reflecting into Map keys for deep path is complex.
-			// Simplified assumption: Maps in Go implementation are map[string]any or unmarshaling to struct.
-			// If map[string]interface{}:
-
-			keyVal := reflect.ValueOf(part)
+			keyVal := reflect.ValueOf(part).Convert(current.Type().Key())
 			existing := current.MapIndex(keyVal)
 
-			var nextVal reflect.Value
-			if existing.IsValid() {
-				// Copy to addressable if needed? MapIndex returns non-addressable.
-				// For maps we probably need to handle intermediate Map/Struct creation.
-				if existing.Kind() == reflect.Interface {
-					existing = existing.Elem()
+			switch elemType := current.Type().Elem(); elemType.Kind() {
+			case reflect.Interface:
+				if existing.IsValid() && !existing.IsNil() {
+					current = existing.Elem()
+					continue
 				}
-				nextVal = existing
-			} else {
-				// Determine type for next level
-				// If map type is map[string]interface{}, create map[string]interface{}
-				mapElemType := current.Type().Elem()
-				if mapElemType.Kind() == reflect.Interface {
-					nextVal = reflect.MakeMap(reflect.TypeOf(map[string]any{}))
-				} else if mapElemType.Kind() == reflect.Ptr {
-					nextVal = reflect.New(mapElemType.Elem())
-				} else {
-					nextVal = reflect.New(mapElemType).Elem()
+				// Untyped levels become map[string]any.
+				next := reflect.MakeMap(reflect.TypeOf(map[string]any{}))
+				current.SetMapIndex(keyVal, next)
+				current = next
+			case reflect.Map, reflect.Ptr:
+				// Maps and pointers are stored first; later path parts
+				// write through them.
+				if !existing.IsValid() || existing.IsNil() {
+					if elemType.Kind() == reflect.Map {
+						existing = reflect.MakeMap(elemType)
+					} else {
+						existing = reflect.New(elemType.Elem())
+					}
+					current.SetMapIndex(keyVal, existing)
 				}
+				current = existing
+			default:
+				// Map values such as structs can't be set in place, so the
+				// rest of the path is decoded into a copy stored back after.
+				elem := reflect.New(elemType).Elem()
+				if existing.IsValid() {
+					elem.Set(existing)
+				}
+				if err := d.setDeepField(elem, strings.Join(parts[i+1:], "."), typ, valStr); err != nil {
+					return err
+				}
+				current.SetMapIndex(keyVal, elem)
+				return nil
 			}
 
-			// Recurse... but wait, 'nextVal' from MapIndex isn't addressable we can't set fields on it easiest way?
-			// Go maps are refs. But reflect.Value of it...
-
-			// This logic for deep map setting via reflection is hard.
-			// Let's assume Structs for now or flat implementation limitations for maps?
-			// For Map[String]Any, we can build a temp map.
-
-			if current.Type().Key().Kind() != reflect.String {
-				return fmt.Errorf("zoon: map key must be string for path %s", path)
-			}
-
-			// NOTE: We need to put it back into the map after modification if it's a struct/value type.
-			// If it's a pointer or map, we can modify it directly.
-
-			// Hack: Create a new map for next level if nil
-			if !nextVal.IsValid() || (nextVal.Kind() == reflect.Map && nextVal.IsNil()) {
-				// Try to guess type? map[string]any
-				nextVal = reflect.MakeMap(reflect.TypeOf(map[string]any{}))
-				current.SetMapIndex(keyVal, nextVal)
-			}
-
-			current = nextVal
-
 		} else if current.Kind() == reflect.Struct {
 			f := findField(current, part)
 			if !f.IsValid() {
-				return nil // Ignore unknown field
+				return d.unknownField(deref(dest), path)
 			}
 			current = f
 		} else {
@@ -471,7 +1164,7 @@ func setDeepField(dest reflect.Value, path, typ, valStr string) error {
 	return nil
 }
 
-func setField(dest reflect.Value, name, typ, valStr string) error {
+func (d *Decoder) setField(dest reflect.Value, name, typ, valStr string) error {
 	dest = deref(dest)
 
 	if dest.Kind() == reflect.Map {
@@ -482,27 +1175,46 @@ func setField(dest reflect.Value, name, typ, valStr string) error {
 		// Handle nested content for map values
 		if strings.HasPrefix(valStr, "{") {
 			// Recursive decode for map value
-			inner := valStr[1 : len(valStr)-1]
 			valType := dest.Type().Elem()
+			if valType.Kind() == reflect.Interface {
+				valType = reflect.TypeOf(map[string]any{})
+			}
 			valElem := reflect.New(valType).Elem()
 
 			// If value is struct/map, use inline parser logic
 			if valType.Kind() == reflect.Struct || valType.Kind() == reflect.Map {
-				subParser := &inlineParser{input: inner}
-				pairs, _ := subParser.parse()
-				for _, p := range pairs {
-					v := p.value
-					if p.sep == "=" {
-						v = strings.ReplaceAll(v, "_", " ")
-					}
-					setDeepField(valElem, p.key, "auto", v)
+				if err := d.setObject(valElem, name, valStr); err != nil {
+					return err
 				}
 				dest.SetMapIndex(reflect.ValueOf(name), valElem)
 				return nil
 			}
 		}
 
-		val := parsePrimitive(valStr, typ)
+		if elemType := dest.Type().Elem(); elemType.Kind() != reflect.Interface {
+			// Typed values convert as struct fields do, so Unmarshaler
+			// types and narrow numbers work as map values too.
+			elem := reflect.New(elemType).Elem()
+			if err := d.setValue(elem, name, typ, valStr); err != nil {
+				return err
+			}
+			dest.SetMapIndex(reflect.ValueOf(name), elem)
+			return nil
+		}
+
+		if strings.HasPrefix(valStr, "[") {
+			elem := reflect.New(dest.Type().Elem()).Elem()
+			if err := d.setValue(elem, name, typ, valStr); err != nil {
+				return err
+			}
+			dest.SetMapIndex(reflect.ValueOf(name), elem)
+			return nil
+		}
+
+		val, err := d.parsePrimitive(valStr, typ)
+		if err != nil {
+			return fmt.Errorf("%w: field %s: %v", ErrInvalidFormat, name, err)
+		}
 		// Check for nil
 		if val == nil {
 			dest.SetMapIndex(reflect.ValueOf(name), reflect.Zero(dest.Type().Elem()))
@@ -513,46 +1225,337 @@ func setField(dest reflect.Value, name, typ, valStr string) error {
 		return nil
 	}
 
+	if name == "" {
+		// The unnamed column of a primitive list sets the element itself.
+		return d.setValue(dest, name, typ, valStr)
+	}
+
 	if dest.Kind() == reflect.Struct {
 		field := findField(dest, name)
 		if !field.IsValid() {
-			return nil
+			return d.unknownField(dest, name)
 		}
+		return d.setValue(field, name, typ, valStr)
+	}
 
-		if strings.HasPrefix(valStr, "{") {
-			inner := valStr[1 : len(valStr)-1]
-			subElem := reflect.New(field.Type()).Elem()
-			subParser := &inlineParser{input: inner}
-			pairs, _ := subParser.parse()
-			for _, p := range pairs {
-				v := p.value
-				if p.sep == "=" {
-					v = strings.ReplaceAll(v, "_", " ")
-				}
-				setDeepField(subElem, p.key, "auto", v)
+	return nil
+}
+
+// unknownField reports a column, named by its full path, with no matching
+// field in strct. Such columns are ignored unless WithStrictFields is set or
+// DisallowUnknownFields called.
+func (d *Decoder) unknownField(strct reflect.Value, name string) error {
+	if !d.strictFields {
+		return nil
+	}
+	return fmt.Errorf("%w: unknown field %q for type %v", ErrInvalidFormat, name, strct.Type())
+}
+
+// setValue parses valStr as typ and stores it in field, converting to the
+// field's type. name is used in error messages.
+func (d *Decoder) setValue(field reflect.Value, name, typ, valStr string) error {
+	if fn, ok := d.funcs[field.Type()]; ok && valStr != "~" {
+		text, _ := d.parsePrimitive(valStr, "s")
+		v, err := fn(text.(string))
+		if err != nil {
+			return fmt.Errorf("%w: field %s: %v", ErrInvalidFormat, name, err)
+		}
+		rv := reflect.ValueOf(v)
+		switch {
+		case !rv.IsValid():
+			field.Set(reflect.Zero(field.Type()))
+		case rv.Type().AssignableTo(field.Type()):
+			field.Set(rv)
+		case rv.Type().ConvertibleTo(field.Type()):
+			field.Set(rv.Convert(field.Type()))
+		default:
+			return fmt.Errorf("%w: field %s: decode func returned %v, want %v", ErrUnsupportedType, name, rv.Type(), field.Type())
+		}
+		return nil
+	}
+
+	if valStr != "~" {
+		if u, ok := unmarshalerFor(field); ok {
+			text, _ := d.parsePrimitive(valStr, "s")
+			if err := u.UnmarshalZoon([]byte(text.(string))); err != nil {
+				return fmt.Errorf("%w: field %s: %v", ErrInvalidFormat, name, err)
 			}
-			field.Set(subElem)
 			return nil
 		}
+	}
+
+	if field.Type() == rawValueType {
+		var raw RawValue
+		if valStr != "~" {
+			raw = RawValue(valStr)
+		}
+		field.Set(reflect.ValueOf(raw))
+		return nil
+	}
 
-		converted := parsePrimitive(valStr, typ)
-		if converted == nil {
-			// Explicit nil
+	if field.Kind() == reflect.Ptr && !isBigType(field.Type()) {
+		// Optional fields: ~ leaves them nil, anything else is stored in a
+		// freshly allocated value.
+		if valStr == "~" {
 			field.Set(reflect.Zero(field.Type()))
 			return nil
 		}
+		elem := reflect.New(field.Type().Elem())
+		if err := d.setValue(elem.Elem(), name, typ, valStr); err != nil {
+			return err
+		}
+		field.Set(elem)
+		return nil
+	}
+
+	if valStr != "~" && isBigType(field.Type()) {
+		if err := parseBig(field, valStr); err != nil {
+			return fmt.Errorf("%w: field %s: %v", ErrInvalidFormat, name, err)
+		}
+		return nil
+	}
 
-		rVal := reflect.ValueOf(converted)
+	if valStr != "~" && field.Type() == durationType {
+		text, _ := d.parsePrimitive(valStr, "s")
+		dur, err := parseDuration(text.(string))
+		if err != nil {
+			return fmt.Errorf("%w: field %s: %v", ErrInvalidFormat, name, err)
+		}
+		field.SetInt(int64(dur))
+		return nil
+	}
+
+	if valStr != "~" && field.Type() == timeType {
+		text, _ := d.parsePrimitive(valStr, "s")
+		t, err := d.parseTime(text.(string), typ)
+		if err != nil {
+			return fmt.Errorf("%w: field %s: %v", ErrInvalidFormat, name, err)
+		}
+		field.Set(reflect.ValueOf(t))
+		return nil
+	}
 
-		if rVal.Type().ConvertibleTo(field.Type()) {
-			field.Set(rVal.Convert(field.Type()))
+	if valStr != "~" && isByteSlice(field.Type()) {
+		b, err := parseBytes(valStr, typ)
+		if err != nil {
+			return fmt.Errorf("%w: field %s: %v", ErrInvalidFormat, name, err)
 		}
+		field.SetBytes(b)
 		return nil
 	}
 
+	if strings.HasPrefix(valStr, "[") && strings.HasSuffix(valStr, "]") {
+		switch field.Kind() {
+		case reflect.Slice, reflect.Array, reflect.Interface:
+			return d.setList(field, name, typ, valStr[1:len(valStr)-1])
+		}
+	}
+
+	if strings.HasPrefix(valStr, "{") {
+		subElem := reflect.New(field.Type()).Elem()
+		if field.Kind() == reflect.Interface && field.NumMethod() == 0 {
+			// An object item of an []any list, or any other bare interface.
+			subElem = reflect.ValueOf(map[string]any{})
+		}
+		if err := d.setObject(subElem, name, valStr); err != nil {
+			return err
+		}
+		field.Set(subElem)
+		return nil
+	}
+
+	converted, err := d.parsePrimitive(valStr, typ)
+	if err != nil {
+		return fmt.Errorf("%w: field %s: %v", ErrInvalidFormat, name, err)
+	}
+	if converted == nil {
+		// Explicit nil
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	}
+
+	rVal := reflect.ValueOf(converted)
+
+	if rVal.Kind() == reflect.String && isFloatKind(field.Kind()) {
+		f, err := strconv.ParseFloat(rVal.String(), field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%w: field %s: invalid float %q", ErrInvalidFormat, name, rVal.String())
+		}
+		field.SetFloat(f)
+		return nil
+	}
+
+	if field.Kind() == reflect.Bool && rVal.Kind() != reflect.Bool && typ != "b" {
+		// An inferred 1 or 0 reads as a number; the field says it's a bool.
+		return d.setValue(field, name, "b", valStr)
+	}
+
+	if isIntKind(field.Kind()) || isUintKind(field.Kind()) {
+		switch n := converted.(type) {
+		case bool:
+			if n {
+				return setInt(field, name, 1)
+			}
+			return setInt(field, name, 0)
+		case int:
+			return setInt(field, name, int64(n))
+		case int64:
+			return setInt(field, name, n)
+		case uint64:
+			return setUint(field, name, n)
+		case *big.Int:
+			return fmt.Errorf("%w: field %s: value %s overflows %v", ErrInvalidFormat, name, n, field.Type())
+		case string:
+			// An untyped token that isn't an int64 or uint64; parse it as an
+			// integer to report overflow or a malformed value.
+			if typ != "i" {
+				return d.setValue(field, name, "i", n)
+			}
+		case float64:
+			if n != math.Trunc(n) {
+				return fmt.Errorf("%w: field %s: fractional value %v for integer field", ErrInvalidFormat, name, n)
+			}
+			if n < math.MinInt64 || n >= math.MaxInt64 {
+				return fmt.Errorf("%w: field %s: value %v overflows %v", ErrInvalidFormat, name, n, field.Type())
+			}
+			return setInt(field, name, int64(n))
+		}
+	}
+
+	if rVal.Type().ConvertibleTo(field.Type()) {
+		field.Set(rVal.Convert(field.Type()))
+	}
 	return nil
 }
 
+// setObject decodes obj, a braced inline object cell, into dest.
+func (d *Decoder) setObject(dest reflect.Value, name, obj string) error {
+	if end, closed := bracedEnd(obj, 0); !closed || end != len(obj) || obj[end-1] != '}' {
+		return fmt.Errorf("%w: field %s: unbalanced braces in %q", ErrInvalidFormat, name, obj)
+	}
+	pairs, err := (&inlineParser{input: obj[1 : len(obj)-1]}).parse()
+	if err != nil {
+		return fmt.Errorf("field %s: %w", name, err)
+	}
+	for _, p := range pairs {
+		typ := "auto"
+		if p.sep == "=" {
+			typ = "s"
+		}
+		if err := d.setDeepField(dest, p.key, typ, p.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setList stores the items of a [...] list cell, given without its
+// brackets, in a slice, array or interface field. Items are separated by
+// spaces or commas, and parsed as the element code of a list type such as
+// i[] when typ is one. Interface fields get an []any.
+func (d *Decoder) setList(field reflect.Value, name, typ, inner string) error {
+	items := tokenizeRow(inner, " ,")
+	list := field
+	switch field.Kind() {
+	case reflect.Slice:
+		list = reflect.MakeSlice(field.Type(), len(items), len(items))
+	case reflect.Interface:
+		list = reflect.ValueOf(make([]any, len(items)))
+	case reflect.Array:
+		if len(items) > field.Len() {
+			return fmt.Errorf("%w: field %s: %d items for %v", ErrInvalidFormat, name, len(items), field.Type())
+		}
+		field.Set(reflect.Zero(field.Type()))
+	}
+
+	if elemCode, ok := strings.CutSuffix(typ, "[]"); ok {
+		typ = elemCode
+	} else if list.Type().Elem().Kind() == reflect.String {
+		typ = "s"
+	} else {
+		typ = "auto"
+	}
+	for i, item := range items {
+		if err := d.setValue(list.Index(i), fmt.Sprintf("%s[%d]", name, i), typ, item); err != nil {
+			return err
+		}
+	}
+	if field.Kind() != reflect.Array {
+		field.Set(list)
+	}
+	return nil
+}
+
+// setInt stores n in an integer field, rejecting values the field's type
+// can't hold rather than letting them wrap.
+func setInt(field reflect.Value, name string, n int64) error {
+	if isUintKind(field.Kind()) {
+		if n < 0 {
+			return fmt.Errorf("%w: field %s: negative value %d for unsigned field", ErrInvalidFormat, name, n)
+		}
+		return setUint(field, name, uint64(n))
+	}
+	if field.OverflowInt(n) {
+		return fmt.Errorf("%w: field %s: value %d overflows %v", ErrInvalidFormat, name, n, field.Type())
+	}
+	field.SetInt(n)
+	return nil
+}
+
+// setUint stores u in an integer field, rejecting values the field's type
+// can't hold rather than letting them wrap.
+func setUint(field reflect.Value, name string, u uint64) error {
+	if isIntKind(field.Kind()) {
+		if u > math.MaxInt64 {
+			return fmt.Errorf("%w: field %s: value %d overflows %v", ErrInvalidFormat, name, u, field.Type())
+		}
+		return setInt(field, name, int64(u))
+	}
+	if field.OverflowUint(u) {
+		return fmt.Errorf("%w: field %s: value %d overflows %v", ErrInvalidFormat, name, u, field.Type())
+	}
+	field.SetUint(u)
+	return nil
+}
+
+var (
+	unmarshalerType     = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// unmarshalerFor returns field as an Unmarshaler, checking both value and
+// pointer receivers and allocating a nil pointer field first. Fields with
+// no UnmarshalZoon but an UnmarshalText are wrapped, except for times and
+// big numbers, which the decoder parses itself.
+func unmarshalerFor(field reflect.Value) (Unmarshaler, bool) {
+	for _, iface := range []reflect.Type{unmarshalerType, textUnmarshalerType} {
+		if iface == textUnmarshalerType && hasBuiltinText(field.Type()) {
+			break
+		}
+		var target reflect.Value
+		if field.Kind() == reflect.Ptr && field.Type().Implements(iface) {
+			if field.IsNil() {
+				field.Set(reflect.New(field.Type().Elem()))
+			}
+			target = field
+		} else if field.CanAddr() && field.Addr().Type().Implements(iface) {
+			target = field.Addr()
+		} else {
+			continue
+		}
+		if u, ok := target.Interface().(Unmarshaler); ok {
+			return u, true
+		}
+		return textUnmarshaler{target.Interface().(encoding.TextUnmarshaler)}, true
+	}
+	return nil, false
+}
+
+// textUnmarshaler adapts an encoding.TextUnmarshaler to Unmarshaler.
+type textUnmarshaler struct{ u encoding.TextUnmarshaler }
+
+func (t textUnmarshaler) UnmarshalZoon(b []byte) error { return t.u.UnmarshalText(b) }
+
 func deref(v reflect.Value) reflect.Value {
 	if v.Kind() == reflect.Ptr {
 		if v.IsNil() {
@@ -563,49 +1566,365 @@ func deref(v reflect.Value) reflect.Value {
 	return v
 }
 
+// findField returns the field of strct named name, allocating nil embedded
+// pointers on the way to a promoted field, or an invalid Value if there is
+// none.
 func findField(strct reflect.Value, name string) reflect.Value {
-	t := strct.Type()
-	for i := 0; i < t.NumField(); i++ {
-		f := t.Field(i)
-		tag := f.Tag.Get("zoon")
-		if tag == "" {
-			tag = f.Tag.Get("json")
+	index := fieldIndex(strct.Type(), name)
+	if index == nil {
+		return reflect.Value{}
+	}
+	v := strct
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
 		}
-		if tag != "" {
-			parts := strings.Split(tag, ",")
-			if parts[0] == name {
-				return strct.Field(i)
+		v = v.Field(x)
+	}
+	return v
+}
+
+// fieldIndex returns the index sequence of the struct field named name, by
+// tag or case-insensitive field name, or nil if there is none. Fields
+// promoted from embedded structs are found as the encoder writes them.
+func fieldIndex(t reflect.Type, name string) []int {
+	fields := structFields(t)
+	for _, f := range fields {
+		if f.opts.name == name {
+			return f.index
+		}
+	}
+	for _, f := range fields {
+		if strings.EqualFold(t.FieldByIndex(f.index).Name, name) {
+			return f.index
+		}
+	}
+	return nil
+}
+
+// typeAtPath returns the Go type a dotted column path resolves to within t,
+// or nil when the path does not name a field.
+func typeAtPath(t reflect.Type, path string) reflect.Type {
+	for _, part := range strings.Split(path, ".") {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		switch t.Kind() {
+		case reflect.Struct:
+			index := fieldIndex(t, part)
+			if index == nil {
+				return nil
 			}
+			t = t.FieldByIndex(index).Type
+		case reflect.Map:
+			t = t.Elem()
+		default:
+			return nil
+		}
+	}
+	return t
+}
+
+// enumValueType returns the type code used to parse an enum option for a
+// destination of type t, so numeric options land in numeric fields.
+func enumValueType(t reflect.Type) string {
+	if t == nil {
+		return "s"
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch k := t.Kind(); {
+	case isIntKind(k):
+		return "i"
+	case isUintKind(k):
+		return "u"
+	case isFloatKind(k):
+		return "f"
+	case isBoolKind(k):
+		return "b"
+	}
+	return "s"
+}
+
+// parseBig sets a big.Int or big.Float field, or a pointer to one, from its
+// decimal form. Floats get enough precision to hold every written digit.
+func parseBig(field reflect.Value, s string) error {
+	if field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			field.Set(reflect.New(field.Type().Elem()))
+		}
+		field = field.Elem()
+	}
+	switch x := field.Addr().Interface().(type) {
+	case *big.Int:
+		if _, ok := x.SetString(s, 10); !ok {
+			return fmt.Errorf("invalid integer %q", s)
+		}
+	case *big.Float:
+		prec := uint(len(s) * 4)
+		if prec < 64 {
+			prec = 64
 		}
-		if strings.EqualFold(f.Name, name) {
-			return strct.Field(i)
+		f, _, err := big.ParseFloat(s, 10, prec, big.ToNearestEven)
+		if err != nil {
+			return err
 		}
+		x.Set(f)
 	}
-	return reflect.Value{}
+	return nil
+}
+
+// parseBytes decodes a byte slice written as hex (type code h) or base64.
+func parseBytes(s, typ string) ([]byte, error) {
+	if s == `""` || s == "" {
+		return []byte{}, nil
+	}
+	if typ == "h" {
+		return hex.DecodeString(s)
+	}
+	return base64.StdEncoding.DecodeString(s)
 }
 
-func parsePrimitive(s, typ string) any {
+func (d *Decoder) parsePrimitive(s, typ string) (any, error) {
 	if s == "~" {
-		return nil
+		return nil, nil
 	}
 
-	if typ == "i" || typ == "i+" {
-		i, _ := strconv.Atoi(s)
-		return i
+	if isQuoted(s) {
+		return unquote(s), nil
+	}
+	if typ == "s" {
+		return d.unescape(s), nil
+	}
+	if _, auto := autoIncStart(typ); typ == "i" || auto {
+		if n, ok := parseInt(s); ok {
+			return n, nil
+		}
+		if u, err := strconv.ParseUint(s, 10, 64); err == nil {
+			return u, nil
+		}
+		if b, ok := new(big.Int).SetString(s, 10); ok {
+			return b, nil
+		}
+		// A column typed from earlier rows may still carry a fractional
+		// value; keep it as a float so setField can reject lossy targets.
+		if f, ok := parseFloatToken(s); ok {
+			return f, nil
+		}
+		return nil, fmt.Errorf("invalid integer %q", s)
+	}
+	if base, ok := intBase(typ); ok {
+		n, ok := new(big.Int).SetString(s, base)
+		switch {
+		case !ok || (typ[0] == 'u' && n.Sign() < 0):
+			return nil, fmt.Errorf("invalid base %d integer %q", base, s)
+		case n.IsInt64():
+			return n.Int64(), nil
+		case n.IsUint64():
+			return n.Uint64(), nil
+		}
+		return n, nil
+	}
+	if typ == "u" {
+		u, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid unsigned integer %q", s)
+		}
+		return u, nil
+	}
+	if isFloatType(typ) {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid float %q", s)
+		}
+		return f, nil
 	}
 	if typ == "b" {
-		return s == "1" || s == "y" || s == "true"
+		return parseBool(s)
+	}
+	if isTimeType(typ) {
+		return d.parseTime(d.unescape(s), typ)
+	}
+	if typ == "dur" {
+		return parseDuration(s)
+	}
+	if typ == "h" || typ == "base64" {
+		return parseBytes(s, typ)
 	}
 
 	if s == "y" || s == "n" {
-		return s == "y"
+		return s == "y", nil
 	}
-	if n, err := strconv.Atoi(s); err == nil {
-		return n
+	if n, ok := parseInt(s); ok {
+		return n, nil
+	}
+	if u, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return u, nil
 	}
 	if s == "true" || s == "false" {
-		return s == "true"
+		return s == "true", nil
+	}
+	if f, ok := parseFloatToken(s); ok {
+		return f, nil
+	}
+
+	return d.unescape(s), nil
+}
+
+// isTimeType reports whether typ is one of the time column type codes.
+func isTimeType(typ string) bool {
+	return typ == "time" || typ == "unix" || typ == "unixms"
+}
+
+// parseTime reads a time written under the type code typ. Other codes,
+// such as those of inline values, fall back to the configured time format.
+func (d *Decoder) parseTime(s, typ string) (time.Time, error) {
+	format := d.timeFormat
+	switch typ {
+	case "time":
+		format = TimeFormatRFC3339
+	case "unix":
+		format = TimeFormatUnix
+	case "unixms":
+		format = TimeFormatUnixMilli
+	}
+	if format == TimeFormatRFC3339 {
+		t, err := time.Parse(d.layout(), s)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid time %q", s)
+		}
+		return t, nil
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid epoch time %q", s)
+	}
+	if format == TimeFormatUnix {
+		return time.Unix(n, 0).UTC(), nil
+	}
+	return time.UnixMilli(n).UTC(), nil
+}
+
+// parseDuration reads a duration written by time.Duration.String. A bare
+// integer is taken as nanoseconds, as durations were once written.
+func parseDuration(s string) (time.Duration, error) {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Duration(n), nil
+	}
+	dur, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return dur, nil
+}
+
+// parseBool reads a bool written in any of the encoder's bool styles.
+func parseBool(s string) (bool, error) {
+	switch s {
+	case "1", "y", "true":
+		return true, nil
+	case "0", "n", "false":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid bool %q", s)
+}
+
+// parseInt parses s as an int64, the type whole numbers take in interface
+// values whatever the platform's int size, as with TranscodeFromJSON.
+func parseInt(s string) (any, bool) {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	return n, true
+}
+
+// parseFloatToken parses s as a float64 when it is written as a decimal or
+// exponent number. Words such as "inf" or "nan" are left alone.
+func parseFloatToken(s string) (float64, bool) {
+	digits := strings.TrimLeft(s, "+-")
+	if len(s)-len(digits) > 1 || digits == "" {
+		return 0, false
+	}
+	if c := digits[0]; (c < '0' || c > '9') && c != '.' {
+		return 0, false
+	}
+	if !strings.ContainsAny(digits, ".eE") {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	return f, err == nil
+}
+
+// autoIncStart returns the first value of an i+ column, which is 1 unless
+// the type code names another start, as in i+0 or i+100.
+func autoIncStart(typ string) (int64, bool) {
+	if typ == "i+" {
+		return 1, true
+	}
+	rest, ok := strings.CutPrefix(typ, "i+")
+	if !ok {
+		return 0, false
+	}
+	start, err := strconv.ParseInt(rest, 10, 64)
+	return start, err == nil
+}
+
+// intBase returns the base of an integer type code written in a base other
+// than 10, such as i36 or u16.
+func intBase(typ string) (int, bool) {
+	if len(typ) < 2 || (typ[0] != 'i' && typ[0] != 'u') {
+		return 0, false
+	}
+	base, err := strconv.Atoi(typ[1:])
+	if err != nil || base < 2 || base > 36 || typ[1] == '+' || typ[1] == '-' {
+		return 0, false
+	}
+	return base, true
+}
+
+// isFloatType reports whether typ is the f type code, optionally followed by
+// the number of decimal places written, as in f2.
+func isFloatType(typ string) bool {
+	if !strings.HasPrefix(typ, "f") {
+		return false
+	}
+	for _, c := range typ[1:] {
+		if c < '0' || c > '9' {
+			return false
+		}
 	}
+	return true
+}
 
+// unescape reverses the encoder's underscore-for-space substitution unless
+// space escaping is disabled.
+func (d *Decoder) unescape(s string) string {
+	if d.noSpaceEscaping || d.separator() != ' ' {
+		return s
+	}
 	return strings.ReplaceAll(s, "_", " ")
 }
+
+// unquote strips the quotes from a quoted token and resolves its escapes.
+func unquote(s string) string {
+	s = s[1 : len(s)-1]
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
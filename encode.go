@@ -1,13 +1,25 @@
 package zoon
 
 import (
+	"context"
+	"encoding"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
+	"io"
+	"math"
+	"math/big"
 	"reflect"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 )
 
-func (e *Encoder) encode(v any) error {
+func (e *Encoder) encode(ctx context.Context, v any) error {
 	val := reflect.ValueOf(v)
 	if val.Kind() == reflect.Ptr {
 		val = val.Elem()
@@ -15,7 +27,13 @@ func (e *Encoder) encode(v any) error {
 
 	switch val.Kind() {
 	case reflect.Slice, reflect.Array:
-		return e.encodeTabular(val)
+		if e.objectRows {
+			return e.encodeObjectRows(ctx, val)
+		}
+		if isPrimitiveList(val.Type()) {
+			return e.encodeList(ctx, val)
+		}
+		return e.encodeTabular(ctx, val)
 	case reflect.Struct, reflect.Map:
 		return e.encodeInline(val)
 	default:
@@ -23,7 +41,50 @@ func (e *Encoder) encode(v any) error {
 	}
 }
 
-func flattenValue(prefix string, v reflect.Value, result map[string]any) {
+// docWriter remembers whether the last byte written ended a line, so a
+// document separator can start on a line of its own.
+type docWriter struct {
+	w        io.Writer
+	written  bool
+	openLine bool
+}
+
+func (w *docWriter) Write(p []byte) (int, error) {
+	if len(p) > 0 {
+		w.written = true
+		w.openLine = p[len(p)-1] != '\n'
+	}
+	return w.w.Write(p)
+}
+
+// startDocument writes the --- line that separates a document from the
+// previous one written by e, if any.
+func (e *Encoder) startDocument() error {
+	if e.out == nil || !e.out.written {
+		return nil
+	}
+	sep := "---\n"
+	if e.out.openLine {
+		sep = "\n" + sep
+	}
+	_, err := io.WriteString(e.out, sep)
+	return err
+}
+
+func (e *Encoder) flattenValue(prefix string, v reflect.Value, result map[string]any) {
+	if strings.Count(prefix, ".") >= maxDepth {
+		panic(marshalError{depthError(v)})
+	}
+	if v.IsValid() && isBigType(v.Type()) {
+		// Arbitrary-precision numbers are leaves, not structs to recurse into.
+		if v.Kind() == reflect.Ptr && v.IsNil() {
+			result[prefix] = nil
+		} else {
+			result[prefix] = v.Interface()
+		}
+		return
+	}
+
 	if v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr {
 		if v.IsNil() {
 			result[prefix] = nil
@@ -32,37 +93,62 @@ func flattenValue(prefix string, v reflect.Value, result map[string]any) {
 		v = v.Elem()
 	}
 
+	if v.IsValid() && (isMarshaler(v.Type()) || v.Type() == timeType) {
+		// Custom types and times are written as one value, not split into
+		// columns.
+		result[prefix] = v.Interface()
+		return
+	}
+
 	if v.Kind() == reflect.Map {
 		keys := v.MapKeys()
-		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		sort.Slice(keys, func(i, j int) bool { return e.lessKey(keys[i].String(), keys[j].String()) })
 		for _, k := range keys {
 			newKey := k.String()
 			if prefix != "" {
 				newKey = prefix + "." + newKey
 			}
-			flattenValue(newKey, v.MapIndex(k), result)
+			if e.skipUnsupported(newKey, v.MapIndex(k)) {
+				continue
+			}
+			e.flattenValue(newKey, v.MapIndex(k), result)
 		}
 	} else if v.Kind() == reflect.Struct {
-		t := v.Type()
-		for i := 0; i < t.NumField(); i++ {
-			f := t.Field(i)
-			name := f.Name
-			tag := f.Tag.Get("zoon")
-			if tag == "" {
-				tag = f.Tag.Get("json")
-			}
-			if tag == "-" {
+		for _, sf := range structFields(v.Type()) {
+			opts := sf.opts
+			field, ok := fieldByIndex(v, sf.index)
+			if !ok || (opts.omitEmpty && isEmptyValue(field)) {
 				continue
 			}
-			if parts := strings.Split(tag, ","); parts[0] != "" {
-				name = parts[0]
-			}
 
-			newKey := name
+			newKey := opts.name
 			if prefix != "" {
 				newKey = prefix + "." + newKey
 			}
-			flattenValue(newKey, v.Field(i), result)
+			if e.skipUnsupported(newKey, field) {
+				continue
+			}
+			if opts.asBool {
+				e.flattenValue(newKey, boolField(newKey, field), result)
+				continue
+			}
+			if opts.asString {
+				e.flattenValue(newKey, stringField(field), result)
+				continue
+			}
+
+			if e.inlineMaps {
+				if fv := reflect.Indirect(field); fv.Kind() == reflect.Map {
+					// Keep the whole map in one cell rather than a column per key.
+					if fv.IsNil() {
+						result[newKey] = nil
+					} else {
+						result[newKey] = fv.Interface()
+					}
+					continue
+				}
+			}
+			e.flattenValue(newKey, field, result)
 		}
 	} else {
 		// Primitive or array (arrays treated as values in tabular for now unless we recursive flatten list items?)
@@ -76,6 +162,257 @@ func flattenValue(prefix string, v reflect.Value, result map[string]any) {
 	}
 }
 
+// fieldOptions holds what a struct field's tag says about encoding it.
+type fieldOptions struct {
+	name      string
+	omitEmpty bool
+	asBool    bool   // a 0/1 integer written as a bool
+	asString  bool   // a number or bool written as a string, as json's ",string"
+	required  bool   // decoding fails if it is left zero
+	def       string // decoded into the field when its input names it nowhere
+}
+
+// parseTag reads the zoon tag of f, falling back to its json tag. ok is
+// false for a field tagged "-" or ",raw", which is never encoded.
+func parseTag(f reflect.StructField) (opts fieldOptions, ok bool) {
+	tag := fieldTag(f)
+	if tag == "-" || isRawField(f) {
+		return opts, false
+	}
+	name, rest, _ := strings.Cut(tag, ",")
+	opts.name = name
+	if name == "" {
+		opts.name = f.Name
+	}
+	for rest != "" {
+		var opt string
+		opt, rest, _ = strings.Cut(rest, ",")
+		switch opt {
+		case "omitempty":
+			opts.omitEmpty = true
+		case "bool":
+			opts.asBool = true
+		case "string":
+			opts.asString = true
+		case "required":
+			opts.required = true
+		default:
+			if def, ok := strings.CutPrefix(opt, "default:"); ok {
+				opts.def = def
+			}
+		}
+	}
+	return opts, true
+}
+
+// structField is a field of a struct type as encoded: one of its own
+// fields, or one promoted from an anonymous struct field.
+type structField struct {
+	index  []int
+	opts   fieldOptions
+	tagged bool // named by its tag rather than its Go name
+}
+
+var fieldCache sync.Map // reflect.Type -> []structField
+
+// structFields returns the encoded fields of t in declaration order, leaving
+// out unexported ones.
+// Anonymous struct fields without a tag name are replaced by their own
+// fields, following Go's promotion rules: of fields sharing a name, the
+// least nested wins, then the only tagged one, and otherwise none is kept.
+func structFields(t reflect.Type) []structField {
+	if fields, ok := fieldCache.Load(t); ok {
+		return fields.([]structField)
+	}
+
+	var all []structField
+	seen := make(map[reflect.Type]bool)
+	var walk func(t reflect.Type, index []int)
+	walk = func(t reflect.Type, index []int) {
+		seen[t] = true
+		defer delete(seen, t)
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			idx := append(slices.Clip(index), i)
+			name, _, _ := strings.Cut(fieldTag(f), ",")
+			if f.Anonymous && name == "" {
+				ft := f.Type
+				if ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+				if ft.Kind() == reflect.Struct && !isMarshaler(ft) && ft != timeType && !isBigType(ft) {
+					// Unexported embedded pointers can't be allocated when
+					// decoding, so their fields are left out.
+					if !seen[ft] && (f.IsExported() || f.Type.Kind() != reflect.Ptr) {
+						walk(ft, idx)
+					}
+					continue
+				}
+			}
+			if !f.IsExported() {
+				// Unexported fields, such as a sync.Mutex, can't be read or
+				// set through reflection.
+				continue
+			}
+			if opts, ok := parseTag(f); ok {
+				all = append(all, structField{idx, opts, name != ""})
+			}
+		}
+	}
+	walk(t, nil)
+
+	byName := make(map[string][]structField)
+	for _, f := range all {
+		byName[f.opts.name] = append(byName[f.opts.name], f)
+	}
+	var fields []structField
+	for _, f := range all {
+		if dominant, ok := dominantField(byName[f.opts.name]); ok && slices.Equal(dominant.index, f.index) {
+			fields = append(fields, f)
+		}
+	}
+	fieldCache.Store(t, fields)
+	return fields
+}
+
+// dominantField returns the field that wins among fields sharing a name,
+// and false when the name is ambiguous.
+func dominantField(fields []structField) (structField, bool) {
+	depth := len(fields[0].index)
+	for _, f := range fields {
+		depth = min(depth, len(f.index))
+	}
+	var shallow, tagged []structField
+	for _, f := range fields {
+		if len(f.index) == depth {
+			shallow = append(shallow, f)
+			if f.tagged {
+				tagged = append(tagged, f)
+			}
+		}
+	}
+	switch {
+	case len(shallow) == 1:
+		return shallow[0], true
+	case len(tagged) == 1:
+		return tagged[0], true
+	}
+	return structField{}, false
+}
+
+// fieldByIndex returns the field of v at index, or false when reaching it
+// means going through a nil embedded pointer.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// boolField returns the bool that v, an integer field tagged ",bool",
+// stands for. Values other than 0 and 1 abort the encode, since they would
+// not survive as a bool.
+func boolField(name string, v reflect.Value) reflect.Value {
+	iv := reflect.Indirect(v)
+	var n uint64
+	switch {
+	case !iv.IsValid():
+		return v
+	case isIntKind(iv.Kind()):
+		if iv.Int() < 0 {
+			n = 2
+		} else {
+			n = uint64(iv.Int())
+		}
+	case isUintKind(iv.Kind()):
+		n = iv.Uint()
+	default:
+		return v
+	}
+	if n > 1 {
+		panic(marshalError{fmt.Errorf("%w: field %s tagged bool holds %v", ErrUnsupportedType, name, iv.Interface())})
+	}
+	return reflect.ValueOf(n == 1)
+}
+
+// stringField returns v, a number or bool field tagged ",string", as its
+// text. Other kinds, and nil pointers, are returned unchanged. Decoding needs
+// no counterpart: cells are parsed by the kind of the field they land in.
+func stringField(v reflect.Value) reflect.Value {
+	iv := reflect.Indirect(v)
+	switch {
+	case !iv.IsValid():
+		return v
+	case isIntKind(iv.Kind()):
+		return reflect.ValueOf(strconv.FormatInt(iv.Int(), 10))
+	case isUintKind(iv.Kind()):
+		return reflect.ValueOf(strconv.FormatUint(iv.Uint(), 10))
+	case iv.Kind() == reflect.Float32 || iv.Kind() == reflect.Float64:
+		return reflect.ValueOf(strconv.FormatFloat(iv.Float(), 'g', -1, iv.Type().Bits()))
+	case iv.Kind() == reflect.Bool:
+		return reflect.ValueOf(strconv.FormatBool(iv.Bool()))
+	}
+	return v
+}
+
+// skipUnsupported reports whether v is a func, chan or unsafe.Pointer,
+// which have no ZOON form and are left out. With WithUnsupportedError set it
+// aborts the encode with ErrUnsupportedType instead.
+func (e *Encoder) skipUnsupported(name string, v reflect.Value) bool {
+	for v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		if e.unsupportedError {
+			panic(marshalError{fmt.Errorf("%w: field %s of kind %v", ErrUnsupportedType, name, v.Kind())})
+		}
+		return true
+	}
+	return false
+}
+
+// fieldTag returns the zoon tag of f, or its json tag when it has none.
+func fieldTag(f reflect.StructField) string {
+	if tag := f.Tag.Get("zoon"); tag != "" {
+		return tag
+	}
+	return f.Tag.Get("json")
+}
+
+// isRawField reports whether f is tagged ",raw" to receive the source text
+// it was decoded from. Raw fields are never encoded.
+func isRawField(f reflect.StructField) bool {
+	_, opts, _ := strings.Cut(fieldTag(f), ",")
+	return slices.Contains(strings.Split(opts, ","), "raw")
+}
+
+// isEmptyValue reports whether v is skipped under omitempty: false, 0, an
+// empty string, slice or map, or a nil pointer or interface.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	}
+	return false
+}
+
 type columnStats struct {
 	name       string
 	kind       reflect.Kind
@@ -85,6 +422,17 @@ type columnStats struct {
 	indexed    bool
 	enumKeys   []string
 	isText     bool
+	isBytes    bool
+	isTime     bool
+	isDuration bool
+	deltas     []string
+	pinnedSum  *int64 // running sum of an i^ column pinned by a schema
+	intBase    int    // base of an integer column written as in i36
+	typeCode   string
+	skip       bool   // i+ column, implied rather than written
+	listCode   string // element type code shared by every list cell
+	hasList    bool   // some cell is a list
+	mixedList  bool   // list cells disagree on their element type code
 }
 
 func detectAliases(keys []string) map[string]string {
@@ -106,6 +454,10 @@ func detectAliases(keys []string) map[string]string {
 	var savings []saving
 
 	for prefix, count := range prefixCounts {
+		if strings.ContainsAny(prefix, " \"=") {
+			// The alias line has no quoting, so such prefixes stay spelled out.
+			continue
+		}
 		prefixLen := len(prefix)
 		// Savings: (len - 2) * count - (len + 4)
 		score := (prefixLen-2)*count - (prefixLen + 4)
@@ -114,7 +466,14 @@ func detectAliases(keys []string) map[string]string {
 		}
 	}
 
-	sort.Slice(savings, func(i, j int) bool { return savings[i].score > savings[j].score })
+	// Ties go to the lexically first prefix, so the letters handed out
+	// don't depend on map iteration order.
+	sort.Slice(savings, func(i, j int) bool {
+		if savings[i].score != savings[j].score {
+			return savings[i].score > savings[j].score
+		}
+		return savings[i].prefix < savings[j].prefix
+	})
 
 	aliases := make(map[string]string)
 	usedAliases := make(map[string]bool)
@@ -157,122 +516,519 @@ func detectAliases(keys []string) map[string]string {
 	return aliases
 }
 
+// headerName quotes a column name that contains a space, a quote or a type
+// separator, so the header still splits into one entry per column.
+func headerName(name string) string {
+	if strings.ContainsAny(name, " \":=!") {
+		return quote(name)
+	}
+	return name
+}
+
+// applyAlias shortens name with the alias of its longest aliased prefix,
+// if any. Taking the longest keeps the choice independent of map iteration
+// order when nested prefixes, such as a and a.b, are both aliased.
 func applyAlias(name string, aliases map[string]string) string {
-	for prefix, alias := range aliases {
-		if strings.HasPrefix(name, prefix+".") {
-			return "%" + alias + name[len(prefix):]
+	best := ""
+	for prefix := range aliases {
+		if (name == prefix || strings.HasPrefix(name, prefix+".")) && len(prefix) > len(best) {
+			best = prefix
 		}
-		if name == prefix {
-			return "%" + alias
+	}
+	if best == "" {
+		return name
+	}
+	return "%" + aliases[best] + name[len(best):]
+}
+
+func (e *Encoder) encodeTabular(ctx context.Context, slice reflect.Value) error {
+	length := slice.Len()
+	if length == 0 {
+		return nil
+	}
+
+	flattened := e.flattenRows(slice)
+	plan := e.planTable(flattened, false)
+	if e.alignColumns && !plan.implicit && e.separator() == ' ' {
+		return e.encodeAligned(ctx, plan, flattened)
+	}
+	if err := e.writeHeader(plan); err != nil {
+		return err
+	}
+	if plan.implicit {
+		return nil
+	}
+
+	for rIdx, row := range flattened {
+		if err := e.writeRow(plan, row, rIdx); err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
 		}
 	}
-	return name
+	return nil
+}
+
+// encodeAligned writes a table with every column padded to its widest cell
+// or header entry, for WithAlignedColumns. Rows are rendered up front to
+// measure them, and the header lists constants and i+ columns before the
+// columns with cells, so each header entry sits above its column.
+func (e *Encoder) encodeAligned(ctx context.Context, plan *tablePlan, flattened []map[string]any) error {
+	rows := make([][]string, len(flattened))
+	for rIdx, row := range flattened {
+		cells, err := e.rowCells(plan, row, rIdx)
+		if err != nil {
+			return err
+		}
+		rows[rIdx] = cells
+	}
+	for _, st := range plan.columns {
+		if !st.skip {
+			plan.widths = append(plan.widths, 0)
+		}
+	}
+	for _, cells := range rows {
+		for i, cell := range cells {
+			plan.widths[i] = max(plan.widths[i], utf8.RuneCountInString(cell))
+		}
+	}
+	if err := e.writeHeader(plan); err != nil {
+		return err
+	}
+
+	for _, cells := range rows {
+		line := strings.Repeat(" ", plan.indent) + padCells(cells, plan.widths)
+		if _, err := fmt.Fprintf(e.w, "%s\n", line); err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (e *Encoder) encodeTabular(slice reflect.Value) error {
+// padCells joins cells with single spaces, padding each but the last to its
+// width.
+func padCells(cells []string, widths []int) string {
+	var b strings.Builder
+	for i, cell := range cells {
+		b.WriteString(cell)
+		if i < len(cells)-1 {
+			b.WriteString(strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell)+1))
+		}
+	}
+	return b.String()
+}
+
+// flattenRows flattens each element of slice into a map keyed by dotted
+// column name.
+func (e *Encoder) flattenRows(slice reflect.Value) []map[string]any {
+	rows := make([]map[string]any, slice.Len())
+	for i := range rows {
+		rows[i] = make(map[string]any)
+		e.flattenValue("", slice.Index(i), rows[i])
+	}
+	return rows
+}
+
+// encodeList writes a slice of primitives as a single unnamed column: a
+// bare "# :type" header followed by one value per line.
+func (e *Encoder) encodeList(ctx context.Context, slice reflect.Value) error {
 	length := slice.Len()
 	if length == 0 {
 		return nil
 	}
 
-	// 1. Flatten Data
-	var flattened []map[string]any
-	keySet := make(map[string]bool)
+	rows := e.flattenRows(slice)
+	st := e.collectStats("", rows, false)
+	e.chooseTypeCode(st, length)
+	plan := &tablePlan{columns: []*columnStats{st}, rows: length}
+	if err := e.writeHeader(plan); err != nil {
+		return err
+	}
+	for rIdx, row := range rows {
+		if err := e.writeRow(plan, row, rIdx); err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isPrimitiveList reports whether t is a slice or array whose elements are
+// written as single values rather than flattened into columns.
+func isPrimitiveList(t reflect.Type) bool {
+	elem := t.Elem()
+	for elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+	if isBigType(elem) || isMarshaler(elem) || elem == timeType {
+		return true
+	}
+	switch elem.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Interface:
+		return false
+	}
+	return true
+}
 
-	for i := 0; i < length; i++ {
-		item := slice.Index(i)
-		rowMap := make(map[string]any)
-		flattenValue("", item, rowMap)
-		flattened = append(flattened, rowMap)
-		for k := range rowMap {
+// encodeChan writes the elements received from ch as one table, deriving
+// the header from the first element.
+func (e *Encoder) encodeChan(ch reflect.Value) error {
+	first, ok := ch.Recv()
+	if !ok {
+		return nil
+	}
+
+	row := make(map[string]any)
+	e.flattenValue("", first, row)
+	plan := e.planTable([]map[string]any{row}, true)
+	if err := e.writeHeader(plan); err != nil {
+		return err
+	}
+	if err := e.writeRow(plan, row, 0); err != nil {
+		return err
+	}
+
+	for rIdx := 1; ; rIdx++ {
+		item, ok := ch.Recv()
+		if !ok {
+			return nil
+		}
+		row := make(map[string]any)
+		e.flattenValue("", item, row)
+		if err := e.writeRow(plan, row, rIdx); err != nil {
+			return err
+		}
+	}
+}
+
+// tablePlan is the header derived from a table's rows: the aliases, the
+// hoisted constants and the typed columns each row is written against.
+type tablePlan struct {
+	aliases   map[string]string
+	constants map[string]any
+	columns   []*columnStats
+	rows      int
+	implicit  bool  // every column is implied, so only +N is written
+	widths    []int // cell widths of the written columns, when aligned
+	indent    int   // leading spaces of an aligned row
+}
+
+// planTable chooses aliases, constants and column types for rows. When
+// sampled is set the rows are only the start of a longer stream, so no
+// column is turned into an i+ sequence.
+func (e *Encoder) planTable(flattened []map[string]any, sampled bool) *tablePlan {
+	length := len(flattened)
+
+	keySet := make(map[string]bool)
+	for _, row := range flattened {
+		for k := range row {
 			keySet[k] = true
 		}
 	}
 
+	// Columns pinned by a schema come first, in schema order, and are
+	// written whether or not the rows hold them.
+	var columns []*columnStats
+	var activeKeys []string
+	if e.schema != nil {
+		for _, def := range e.schema.Columns {
+			columns = append(columns, def.stats())
+			activeKeys = append(activeKeys, def.Name)
+			delete(keySet, def.Name)
+		}
+	}
+
 	var allKeys []string
 	for k := range keySet {
 		allKeys = append(allKeys, k)
 	}
-	sort.Strings(allKeys)
+	slices.SortFunc(allKeys, e.compareKeys)
 
 	// 2. Identify Constants
 	constants := make(map[string]any)
-	var activeKeys []string
+	var autoKeys []string
 
-	if length > 1 {
+	if length >= e.constantRows() && !e.noHoisting {
 		for _, k := range allKeys {
 			isConst := true
 			first := flattened[0][k]
 			for _, row := range flattened {
-				val := row[k]
-				if val != first && (val != nil || first != nil) { // Go equality check
-					// Deep check for maps/slices?
-					if !reflect.DeepEqual(val, first) {
-						isConst = false
-						break
-					}
+				// DeepEqual rather than != since slices are not comparable.
+				if !reflect.DeepEqual(row[k], first) {
+					isConst = false
+					break
 				}
 			}
-			if isConst && first != nil {
+			if isConst && isHoistable(first) && !isQuoted(e.serializeValue(reflect.ValueOf(first))) {
 				constants[k] = first
 			} else {
-				activeKeys = append(activeKeys, k)
+				autoKeys = append(autoKeys, k)
 			}
 		}
 	} else {
-		activeKeys = allKeys
+		autoKeys = allKeys
 	}
 
 	// 3. Stats & Types for Active Keys
-	stats := make(map[string]*columnStats)
-	for _, k := range activeKeys {
-		s := &columnStats{
-			name:       k,
-			uniqueVals: make(map[string]bool),
-		}
-		for _, row := range flattened {
-			v := row[k]
-
-			// Detect kind logic
-			valRef := reflect.ValueOf(v)
-			if !valRef.IsValid() || (valRef.Kind() == reflect.Ptr && valRef.IsNil()) {
-				// sVal = "~" handles later
-			} else {
-				if s.kind == reflect.Invalid {
-					s.kind = valRef.Kind()
-				} else if s.kind != valRef.Kind() {
-					s.kind = reflect.String // mixed types fallback
+	for _, k := range autoKeys {
+		st := e.collectStats(k, flattened, sampled)
+		e.chooseTypeCode(st, length)
+		columns = append(columns, st)
+	}
+	activeKeys = append(activeKeys, autoKeys...)
+
+	// +N optimization: when every active column is an i+ sequence (or there
+	// are none), rows carry no data and only the count is written.
+	implicit := !sampled
+	for _, st := range columns {
+		if !st.skip {
+			implicit = false
+			break
+		}
+	}
+
+	var aliases map[string]string
+	if !e.noAliases {
+		aliases = detectAliases(activeKeys)
+	}
+
+	return &tablePlan{
+		aliases:   aliases,
+		constants: constants,
+		columns:   columns,
+		rows:      length,
+		implicit:  implicit,
+	}
+}
+
+// collectStats gathers the serialized values and kind of column k.
+func (e *Encoder) collectStats(k string, flattened []map[string]any, sampled bool) *columnStats {
+	s := &columnStats{
+		name:       k,
+		uniqueVals: make(map[string]bool),
+	}
+	for _, row := range flattened {
+		v := row[k]
+
+		// Detect kind logic
+		valRef := reflect.ValueOf(v)
+		if !valRef.IsValid() || (valRef.Kind() == reflect.Ptr && valRef.IsNil()) {
+			// sVal = "~" handles later
+		} else {
+			kind := valueKind(valRef)
+			if s.kind == reflect.Invalid {
+				s.kind = kind
+			} else if s.kind != kind && isNumberKind(s.kind) && isNumberKind(kind) && (isFloatKind(s.kind) || isFloatKind(kind)) {
+				s.kind = reflect.Float64 // whole numbers mixed with fractions
+			} else if s.kind != kind {
+				s.kind = reflect.String // mixed types fallback
+			}
+			if isByteSlice(valRef.Type()) && valRef.Type() != rawValueType && !isMarshaler(valRef.Type()) {
+				s.isBytes = true
+			}
+			if code, ok := listElemCode(reflect.Indirect(valRef)); ok {
+				if s.hasList && s.listCode != code {
+					s.mixedList = true
 				}
+				s.listCode, s.hasList = code, true
+			}
+			switch reflect.Indirect(valRef).Type() {
+			case timeType:
+				s.isTime = true
+			case durationType:
+				s.isDuration = true
 			}
+		}
+
+		// Value for sequencing
+		if k == "id" && canBeInt(s.kind) && !sampled {
+			s.isSeq = true // candidate
+		}
 
-			// Value for sequencing
-			if k == "id" && canBeInt(s.kind) {
-				s.isSeq = true // candidate
+		sVal := e.serializeValue(valRef)
+		s.values = append(s.values, sVal)
+		s.uniqueVals[sVal] = true
+	}
+	return s
+}
+
+// chooseTypeCode sets the header type code for a column from its stats.
+func (e *Encoder) chooseTypeCode(st *columnStats, length int) {
+	typeCode := "s"
+
+	if st.isSeq {
+		// Consecutive integers from any start are implied by i+, with the
+		// start appended unless it is 1.
+		start, err := strconv.ParseInt(st.values[0], 10, 64)
+		isSeq := err == nil
+		for idx, val := range st.values {
+			if !isSeq || val != strconv.FormatInt(start+int64(idx), 10) {
+				isSeq = false
+				break
+			}
+		}
+		if isSeq {
+			typeCode = "i+"
+			if start != 1 {
+				typeCode += strconv.FormatInt(start, 10)
+			}
+			st.skip = true
+		} else if isUintKind(st.kind) {
+			typeCode = "u"
+		} else {
+			typeCode = "i"
+		}
+	} else if st.isTime && st.kind == reflect.String {
+		typeCode = e.timeCode()
+	} else if st.isDuration && st.kind == reflect.String {
+		typeCode = "dur"
+	} else if isBoolKind(st.kind) {
+		typeCode = "b"
+	} else if isIntKind(st.kind) {
+		typeCode = "i"
+	} else if isUintKind(st.kind) {
+		typeCode = "u"
+	} else if isFloatKind(st.kind) {
+		typeCode = "f"
+		if prec := e.precision(); prec >= 0 {
+			typeCode += strconv.Itoa(prec)
+		}
+	} else if st.isBytes {
+		typeCode = "base64"
+		if e.byteEncoding == ByteEncodingHex {
+			typeCode = "h"
+		}
+	} else if st.kind == reflect.Map || st.kind == reflect.Slice || st.kind == reflect.Array {
+		// Inline object and list cells are never enums or quoted text.
+		if st.listCode != "" && !st.mixedList {
+			typeCode = st.listCode + "[]"
+		}
+	} else {
+		if e.isEnumCandidate(len(st.uniqueVals), length) {
+			var keys []string
+			quoted := false
+			for k := range st.uniqueVals {
+				if k != "~" {
+					keys = append(keys, k)
+				}
+				quoted = quoted || isQuoted(k)
+			}
+			sort.Strings(keys)
+			if quoted {
+				// Quoted values contain spaces, which the header can't hold.
+			} else if len(keys) >= 3 {
+				avgLen := 0
+				for _, k := range keys {
+					avgLen += len(k)
+				}
+				avgLen = avgLen / len(keys)
+				literalCost := avgLen * length
+				indexCost := len(strings.Join(keys, "|")) + length*2
+				if saving := literalCost - indexCost; saving > 0 && float64(saving) >= e.enumIndexSaving*float64(literalCost) {
+					typeCode = "!" + strings.Join(keys, "|")
+					st.indexed = true
+					st.enumKeys = keys
+				} else {
+					typeCode = "=" + strings.Join(keys, "|")
+					st.enumKeys = keys
+				}
+			} else if len(keys) > 0 {
+				typeCode = "=" + strings.Join(keys, "|")
+				st.enumKeys = keys
+			}
+		} else {
+			totalLen := 0
+			for _, v := range st.values {
+				totalLen += len(v)
+			}
+			if len(st.values) > 0 && totalLen/len(st.values) > e.textLength() {
+				typeCode = "t"
+				st.isText = true
 			}
 		}
-		stats[k] = s
 	}
 
-	// Collect String Values for active keys
-	for _, row := range flattened {
-		for _, k := range activeKeys {
-			v := row[k]
-			sVal := serializeValue(reflect.ValueOf(v))
-			stats[k].values = append(stats[k].values, sVal)
-			stats[k].uniqueVals[sVal] = true
+	if typeCode == "i" && e.deltaEncoding {
+		if deltas := deltaEncode(st.values); deltas != nil {
+			typeCode = "i^"
+			st.deltas = deltas
 		}
 	}
+	if (typeCode == "i" || typeCode == "u") && e.intBase >= 2 && e.intBase <= 36 && e.intBase != 10 {
+		typeCode += strconv.Itoa(e.intBase)
+		st.intBase = e.intBase
+	}
 
-	// 4. Aliases
-	aliases := detectAliases(activeKeys)
+	st.typeCode = typeCode
+}
 
-	// 5. Build Header
+// isEnumCandidate reports whether a string column with unique distinct
+// values over length rows may be written as an enum.
+func (e *Encoder) isEnumCandidate(unique, length int) bool {
+	if unique > e.maxEnumOptions() || unique < e.enumMinOptions || unique >= length {
+		return false
+	}
+	return e.enumMinRatio <= 0 || float64(length) >= e.enumMinRatio*float64(unique)
+}
+
+// isSlicePrimitive reports whether t is a slice or array of strings, bools
+// or numbers, written as a typed list such as [a b] under s[] or [1 2] under
+// i[]. Byte slices, which are written as base64, and elements that are
+// durations, big numbers or custom types don't qualify.
+func isSlicePrimitive(t reflect.Type) bool {
+	if (t.Kind() != reflect.Slice && t.Kind() != reflect.Array) || isByteSlice(t) {
+		return false
+	}
+	elem := t.Elem()
+	if elem == durationType || isBigType(elem) || isMarshaler(elem) {
+		return false
+	}
+	switch kind := elem.Kind(); {
+	case kind == reflect.String, isBoolKind(kind), isNumberKind(kind):
+		return true
+	}
+	return false
+}
+
+// listElemCode returns the type code of the elements of a list value, such
+// as i for an []int. ok is false for values that are not lists, and code
+// is empty for lists whose elements have no single primitive code.
+func listElemCode(v reflect.Value) (code string, ok bool) {
+	if (v.Kind() != reflect.Slice && v.Kind() != reflect.Array) || isByteSlice(v.Type()) {
+		return "", false
+	}
+	if !isSlicePrimitive(v.Type()) {
+		return "", true
+	}
+	switch kind := v.Type().Elem().Kind(); {
+	case kind == reflect.String:
+		return "s", true
+	case isBoolKind(kind):
+		return "b", true
+	case isIntKind(kind):
+		return "i", true
+	case isUintKind(kind):
+		return "u", true
+	case isFloatKind(kind):
+		return "f", true
+	}
+	return "", true
+}
+
+// writeHeader writes the alias line, if any, and the # header line.
+func (e *Encoder) writeHeader(plan *tablePlan) error {
 	var lines []string
 
 	// Alias Defs
-	if len(aliases) > 0 {
+	if len(plan.aliases) > 0 {
 		var parts []string
-		for prefix, alias := range aliases {
+		for prefix, alias := range plan.aliases {
 			parts = append(parts, fmt.Sprintf("%%%s=%s", alias, prefix))
 		}
 		sort.Slice(parts, func(i, j int) bool { return parts[i] < parts[j] }) // deterministic
@@ -285,204 +1041,186 @@ func (e *Encoder) encodeTabular(slice reflect.Value) error {
 	// Constants
 	// Need sorted keys for deterministic output
 	var constKeys []string
-	for k := range constants {
+	for k := range plan.constants {
 		constKeys = append(constKeys, k)
 	}
-	sort.Strings(constKeys)
+	slices.SortFunc(constKeys, e.compareKeys)
 
 	for _, k := range constKeys {
-		val := constants[k]
-		aliased := applyAlias(k, aliases)
-		aliased = strings.ReplaceAll(aliased, " ", "_")
+		val := plan.constants[k]
+		aliased := headerName(applyAlias(k, plan.aliases))
 
-		sVal := serializeValue(reflect.ValueOf(val))
+		sVal := e.serializeValue(reflect.ValueOf(val))
 		typeCode := ":" // inferred
-		if _, ok := val.(string); ok {
+		b, isBool := val.(bool)
+		_, isTime := val.(time.Time)
+		_, isDuration := val.(time.Duration)
+		if _, ok := val.(string); ok || (isTime && !e.explicitTypes && e.timeFormat == TimeFormatRFC3339) || (isDuration && !e.explicitTypes) {
 			typeCode = "="
-		}
-
-		if typeCode == ":" {
-			if b, ok := val.(bool); ok {
-				if b {
-					sVal = "y"
-				} else {
-					sVal = "n"
-				}
+		} else if e.explicitTypes || isTime || (isBool && e.boolStyle == BoolStyleOneZero) {
+			// 1/0 would be inferred as an integer, so it needs the b code,
+			// and an epoch time needs its unit.
+			typeCode = ":" + e.constTypeCode(reflect.ValueOf(val)) + "="
+			if isBool {
+				sVal = e.formatBool(b, true)
 			}
+		} else if isBool {
+			sVal = e.formatBool(b, false)
 		}
 		headerParts = append(headerParts, fmt.Sprintf("@%s%s%s", aliased, typeCode, sVal))
 	}
 
-	var skipIndices []int
-
-	for i, k := range activeKeys {
-		st := stats[k]
-		typeCode := "s"
-
-		aliased := applyAlias(k, aliases)
-		aliased = strings.ReplaceAll(aliased, " ", "_")
+	var cellParts []string
+	for _, st := range plan.columns {
+		aliased := headerName(applyAlias(st.name, plan.aliases))
 
-		if st.isSeq {
-			isSeq := true
-			for idx, val := range st.values {
-				if val != fmt.Sprintf("%d", idx+1) {
-					isSeq = false
-					break
-				}
-			}
-			if isSeq {
-				typeCode = "i+"
-				skipIndices = append(skipIndices, i)
-			} else {
-				typeCode = "i"
-			}
-		} else if isBoolKind(st.kind) {
-			typeCode = "b"
-		} else if isIntKind(st.kind) {
-			typeCode = "i"
-		} else {
-			if len(st.uniqueVals) <= 10 && len(st.uniqueVals) < length {
-				var keys []string
-				for k := range st.uniqueVals {
-					if k != "~" {
-						keys = append(keys, k)
-					}
-				}
-				sort.Strings(keys)
-				if len(keys) >= 3 {
-					avgLen := 0
-					for _, k := range keys {
-						avgLen += len(k)
-					}
-					avgLen = avgLen / len(keys)
-					literalCost := avgLen * length
-					indexCost := len(strings.Join(keys, "|")) + length*2
-					if literalCost > indexCost {
-						typeCode = "!" + strings.Join(keys, "|")
-						st.indexed = true
-						st.enumKeys = keys
-					} else {
-						typeCode = "=" + strings.Join(keys, "|")
-						st.enumKeys = keys
-					}
-				} else if len(keys) > 0 {
-					typeCode = "=" + strings.Join(keys, "|")
-					st.enumKeys = keys
-				}
-			} else {
-				totalLen := 0
-				for _, v := range st.values {
-					totalLen += len(v)
-				}
-				if len(st.values) > 0 && totalLen/len(st.values) > 30 {
-					typeCode = "t"
-					st.isText = true
-				}
-			}
+		part := fmt.Sprintf("%s:%s", aliased, st.typeCode)
+		if strings.HasPrefix(st.typeCode, "=") || strings.HasPrefix(st.typeCode, "!") {
+			part = aliased + st.typeCode
 		}
-
-		if strings.HasPrefix(typeCode, "=") || strings.HasPrefix(typeCode, "!") {
-			headerParts = append(headerParts, aliased+typeCode)
+		if plan.widths != nil && !st.skip {
+			cellParts = append(cellParts, part)
 		} else {
-			headerParts = append(headerParts, fmt.Sprintf("%s:%s", aliased, typeCode))
+			headerParts = append(headerParts, part)
 		}
 	}
 
-	// +N optimization
-	// Determine if all active columns are non-consuming (i.e., i+ or constant)
-	// We rely on skipIndices which are populated for i+
-	// Constants are stripped from activeKeys entirely.
+	if plan.implicit {
+		headerParts = append(headerParts, fmt.Sprintf("+%d", plan.rows))
+	}
 
-	allSkipped := true
-	if len(activeKeys) == 0 {
-		// If no active keys (all implicit), then row is empty/skipped
-	} else {
-		for i := 0; i < len(activeKeys); i++ {
-			skipped := false
-			for _, s := range skipIndices {
-				if i == s {
-					skipped = true
-					break
-				}
-			}
-			if !skipped {
-				allSkipped = false
-				break
-			}
+	header := strings.Join(headerParts, " ")
+	if plan.widths != nil {
+		// Cells start under the first column entry.
+		plan.indent = utf8.RuneCountInString(header) + 1
+		for i, part := range cellParts {
+			plan.widths[i] = max(plan.widths[i], utf8.RuneCountInString(part))
 		}
+		header += " " + padCells(cellParts, plan.widths)
 	}
+	lines = append(lines, header)
+	_, err := fmt.Fprintf(e.w, "%s\n", strings.Join(lines, "\n"))
+	return err
+}
 
-	// fmt.Printf("DEBUG: active=%v skip=%v allSkipped=%v\n", activeKeys, skipIndices, allSkipped)
-
-	if allSkipped && length > 0 {
-		headerParts = append(headerParts, fmt.Sprintf("+%d", length))
+// constTypeCode returns the type code written for a hoisted constant under
+// explicit types.
+func (e *Encoder) constTypeCode(v reflect.Value) string {
+	switch kind := valueKind(v); {
+	case v.Type() == timeType:
+		return e.timeCode()
+	case v.Type() == durationType:
+		return "dur"
+	case isBoolKind(kind):
+		return "b"
+	case isIntKind(kind):
+		return "i"
+	case isUintKind(kind):
+		return "u"
+	case isFloatKind(kind):
+		return "f"
+	default:
+		return "s"
 	}
+}
 
-	lines = append(lines, strings.Join(headerParts, " "))
-
-	headerBlock := strings.Join(lines, "\n")
-
-	if allSkipped {
-		fmt.Fprintf(e.w, "%s\n", headerBlock)
-		return nil
+// writeRow writes one flattened row, the rIdx-th of the table, against plan.
+func (e *Encoder) writeRow(plan *tablePlan, row map[string]any, rIdx int) error {
+	outRow, err := e.rowCells(plan, row, rIdx)
+	if err != nil {
+		return err
 	}
+	_, err = fmt.Fprintf(e.w, "%s\n", strings.Join(outRow, string(e.separator())))
+	return err
+}
 
-	fmt.Fprintf(e.w, "%s\n", headerBlock)
+// rowCells renders the cells of one flattened row, the rIdx-th of the
+// table, against plan.
+func (e *Encoder) rowCells(plan *tablePlan, row map[string]any, rIdx int) ([]string, error) {
+	var outRow []string
+	for _, st := range plan.columns {
+		if st.skip {
+			continue
+		}
 
-	for rIdx, row := range flattened {
-		var outRow []string
-		for i, k := range activeKeys {
-			// Check skip
-			skipped := false
-			for _, s := range skipIndices {
-				if i == s {
-					skipped = true
-					break
-				}
+		// Warning: we need to respect the typeCode chosen.
+		// If we chose 'b', we need 0/1. If 'i', number.
+
+		rawVal := row[st.name]
+		valRef := reflect.ValueOf(rawVal)
+		sVal := e.serializeValue(valRef)
+
+		if st.deltas != nil {
+			sVal = st.deltas[rIdx]
+		} else if st.pinnedSum != nil && sVal != "~" {
+			// A schema's i^ column has no precomputed deltas, so they are
+			// taken row by row, which also serves EncodeChan.
+			n, err := strconv.ParseInt(sVal, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%w: value %q of delta column %s is not an integer", ErrInvalidFormat, sVal, st.name)
 			}
-			if skipped {
-				continue
+			sVal = strconv.FormatInt(n-*st.pinnedSum, 10)
+			*st.pinnedSum = n
+		} else if st.typeCode == "h" {
+			if valRef.IsValid() && isByteSlice(valRef.Type()) && valRef.Len() > 0 {
+				sVal = hex.EncodeToString(valRef.Bytes())
 			}
-
-			// Stats for type check (bool conversion)
-			// Actually we already serialized in stats, use that or re-serialize?
-			// Re-serialize with bool logic
-
-			// Warning: we need to respect the typeCode chosen.
-			// If we chose 'b', we need 0/1. If 'i', number.
-
-			rawVal := row[k]
-			valRef := reflect.ValueOf(rawVal)
-			sVal := serializeValue(valRef)
-
-			if isBoolKind(stats[k].kind) {
-				if sVal == "true" {
-					sVal = "1"
-				} else if sVal == "false" {
-					sVal = "0"
-				}
-			} else if stats[k].indexed && len(stats[k].enumKeys) > 0 {
-				for idx, enumVal := range stats[k].enumKeys {
-					if sVal == enumVal {
-						sVal = fmt.Sprintf("%d", idx)
-						break
-					}
-				}
-			} else if stats[k].isText {
-				rawStr := ""
-				if s, ok := rawVal.(string); ok {
-					rawStr = s
-				} else {
-					rawStr = fmt.Sprintf("%v", rawVal)
-				}
-				sVal = `"` + strings.ReplaceAll(rawStr, `"`, `\"`) + `"`
+		} else if st.intBase != 0 {
+			if n, ok := new(big.Int).SetString(sVal, 10); ok {
+				sVal = n.Text(st.intBase)
+			}
+		} else if isBoolKind(st.kind) {
+			if sVal == "true" || sVal == "false" {
+				sVal = e.formatBool(sVal == "true", true)
+			}
+		} else if len(st.enumKeys) > 0 && sVal != "~" {
+			// Enums pinned by a schema may not cover every value.
+			idx := slices.Index(st.enumKeys, sVal)
+			if idx < 0 {
+				return nil, fmt.Errorf("%w: value %q is not an option of column %s", ErrInvalidFormat, sVal, st.name)
+			}
+			if st.indexed {
+				sVal = strconv.Itoa(idx)
+			}
+		} else if st.isText && sVal != "~" {
+			rawStr := ""
+			if s, ok := rawVal.(string); ok {
+				rawStr = s
+			} else {
+				rawStr = fmt.Sprintf("%v", rawVal)
 			}
-			outRow = append(outRow, sVal)
+			sVal = quote(rawStr)
+		} else if str, ok := rawVal.(string); ok && e.separator() != ' ' {
+			sVal = e.formatCell(str)
 		}
-		fmt.Fprintf(e.w, "%s\n", strings.Join(outRow, " "))
-		_ = rIdx
+		if e.nullSymbol != "" {
+			if sVal == "~" {
+				sVal = e.nullSymbol
+			} else if sVal == e.nullSymbol {
+				sVal = quote(sVal)
+			}
+		}
+		outRow = append(outRow, sVal)
 	}
+	return outRow, nil
+}
 
+// encodeObjectRows writes each element as a braced inline object on its own
+// line, so rows with unrelated keys need no shared header.
+func (e *Encoder) encodeObjectRows(ctx context.Context, slice reflect.Value) error {
+	for i := 0; i < slice.Len(); i++ {
+		var buf strings.Builder
+		enc := &Encoder{w: &buf, encoderConfig: e.encoderConfig}
+		if err := enc.encodeInline(slice.Index(i)); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(e.w, "{%s}\n", buf.String()); err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -495,28 +1233,28 @@ func (e *Encoder) encodeInline(val reflect.Value) error {
 
 	if val.Kind() == reflect.Map {
 		keys := val.MapKeys()
-		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		sort.Slice(keys, func(i, j int) bool { return e.lessKey(keys[i].String(), keys[j].String()) })
 		for _, k := range keys {
 			v := val.MapIndex(k)
-			parts = append(parts, formatInlinePair(k.String(), v))
+			if e.skipUnsupported(k.String(), v) {
+				continue
+			}
+			parts = append(parts, e.formatInlinePair(k.String(), v))
 		}
 	} else if val.Kind() == reflect.Struct {
-		t := val.Type()
-		for i := 0; i < t.NumField(); i++ {
-			f := t.Field(i)
-			tag := f.Tag.Get("zoon")
-			if tag == "" {
-				tag = f.Tag.Get("json")
-			}
-			if tag == "-" {
+		for _, sf := range structFields(val.Type()) {
+			opts := sf.opts
+			fv, ok := fieldByIndex(val, sf.index)
+			if !ok || (opts.omitEmpty && isEmptyValue(fv)) || e.skipUnsupported(opts.name, fv) {
 				continue
 			}
-			name := f.Name
-			if parts := strings.Split(tag, ","); parts[0] != "" {
-				name = parts[0]
-			}
 
-			parts = append(parts, formatInlinePair(name, val.Field(i)))
+			if opts.asBool {
+				fv = boolField(opts.name, fv)
+			} else if opts.asString {
+				fv = stringField(fv)
+			}
+			parts = append(parts, e.formatInlinePair(opts.name, fv))
 		}
 	}
 
@@ -524,60 +1262,444 @@ func (e *Encoder) encodeInline(val reflect.Value) error {
 	return err
 }
 
-func formatInlinePair(key string, v reflect.Value) string {
-	valStr := serializeValue(v)
-	if v.Kind() == reflect.String {
-		valStr = strings.ReplaceAll(valStr, " ", "_")
+func (e *Encoder) formatInlinePair(key string, v reflect.Value) string {
+	// Values held in map[string]any arrive as interfaces; pick the
+	// representation from the concrete kind underneath.
+	for (v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr) && !v.IsNil() {
+		v = v.Elem()
+	}
+
+	valStr := e.serializeValue(v)
+	if _, ok := marshalerFor(v); ok || v.Kind() == reflect.String || v.Type() == durationType || (v.Type() == timeType && e.timeFormat == TimeFormatRFC3339) {
 		return fmt.Sprintf("%s=%s", key, valStr)
 	}
 
 	if v.Kind() == reflect.Bool {
-		if v.Bool() {
-			return fmt.Sprintf("%s:y", key)
-		}
-		return fmt.Sprintf("%s:n", key)
+		return fmt.Sprintf("%s:%s", key, e.formatBool(v.Bool(), false))
 	}
 
 	return fmt.Sprintf("%s:%s", key, valStr)
 }
 
-func serializeValue(v reflect.Value) string {
+func (e *Encoder) serializeValue(v reflect.Value) string {
 	if !v.IsValid() {
 		return "~"
 	}
+	if m, ok := marshalerFor(v); ok {
+		b, err := m.MarshalZoon()
+		if err != nil {
+			panic(marshalError{fmt.Errorf("zoon: MarshalZoon for %v: %w", v.Type(), err)})
+		}
+		return e.serializeValue(reflect.ValueOf(string(b)))
+	}
 	if v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
 		if v.IsNil() {
 			return "~"
 		}
-		return serializeValue(v.Elem())
+		return e.serializeValue(v.Elem())
+	}
+
+	if v.Type() == rawValueType {
+		if v.Len() == 0 {
+			return "~"
+		}
+		return string(v.Bytes())
+	}
+	if isByteSlice(v.Type()) {
+		if v.IsNil() {
+			return "~"
+		}
+		return e.formatBytes(v.Bytes())
+	}
+	if v.Type() == timeType && v.CanInterface() {
+		return e.formatTime(v.Interface().(time.Time))
+	}
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String()
+	}
+	if s, ok := formatBig(v); ok {
+		return s
 	}
 
 	switch v.Kind() {
 	case reflect.String:
-		s := v.String()
-		return strings.ReplaceAll(s, " ", "_")
+		return e.formatString(v.String())
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		return fmt.Sprintf("%d", v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10)
 	case reflect.Bool:
 		return fmt.Sprintf("%t", v.Bool())
+	case reflect.Float32:
+		return e.formatFloat(v.Float(), 32)
+	case reflect.Float64:
+		return e.formatFloat(v.Float(), 64)
 	case reflect.Struct, reflect.Map:
+		if e.depth >= maxDepth {
+			panic(marshalError{depthError(v)})
+		}
 		var buf strings.Builder
-		enc := &Encoder{w: &buf}
+		enc := &Encoder{w: &buf, depth: e.depth + 1, encoderConfig: e.encoderConfig}
 		if err := enc.encodeInline(v); err != nil {
 			return "{error}"
 		}
 		return "{" + buf.String() + "}"
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return "~"
+		}
+		items := make([]string, v.Len())
+		for i := range items {
+			item := v.Index(i)
+			for item.Kind() == reflect.Interface && !item.IsNil() {
+				item = item.Elem()
+			}
+			if item.Kind() == reflect.Bool {
+				items[i] = e.formatBool(item.Bool(), false)
+				continue
+			}
+			items[i] = e.serializeValue(item)
+			nested := item.Kind() == reflect.Slice || item.Kind() == reflect.Array || item.Kind() == reflect.Map || item.Kind() == reflect.Struct
+			if !isQuoted(items[i]) && (strings.Contains(items[i], ",") || (!nested && strings.ContainsAny(items[i], "[]"))) {
+				// Commas separate items too, and a bracket in a value would
+				// unbalance the list.
+				items[i] = quote(items[i])
+			}
+		}
+		return "[" + strings.Join(items, " ") + "]"
 	default:
 		return fmt.Sprintf("%v", v)
 	}
 }
 
+// formatBool renders b in the configured bool style. cell selects the
+// default style's form for table cells and typed constants, 1/0, over the
+// y/n used elsewhere.
+func (e *Encoder) formatBool(b bool, cell bool) string {
+	t, f := "y", "n"
+	switch {
+	case e.boolStyle == BoolStyleTrueFalse:
+		t, f = "true", "false"
+	case e.boolStyle == BoolStyleOneZero, e.boolStyle == BoolStyleDefault && cell:
+		t, f = "1", "0"
+	}
+	if b {
+		return t
+	}
+	return f
+}
+
+// formatString renders s as a single token. Spaces become underscores, so a
+// string that already holds an underscore is quoted instead, as is anything
+// that needsQuotes. With space escaping off, or a column separator other
+// than a space, strings with spaces are quoted and underscores kept as is.
+// WithQuotedStrings quotes every string.
+func (e *Encoder) formatString(s string) string {
+	if needsQuotes(s) || e.quoteStrings {
+		return quote(s)
+	}
+	if e.noSpaceEscaping || e.separator() != ' ' {
+		if strings.Contains(s, " ") {
+			return quote(s)
+		}
+		return s
+	}
+	if strings.Contains(s, "_") {
+		return quote(s)
+	}
+	return strings.ReplaceAll(s, " ", "_")
+}
+
+// formatCell renders s as a table cell under a column separator other than a
+// space, where spaces need no escaping. Only cells holding the separator,
+// or whose edge spaces the decoder would trim, are quoted.
+func (e *Encoder) formatCell(s string) string {
+	if needsQuotes(s) || e.quoteStrings || strings.IndexByte(s, e.separator()) >= 0 || strings.TrimSpace(s) != s {
+		return quote(s)
+	}
+	return s
+}
+
+// needsQuotes reports whether s would read back differently unquoted: an
+// empty string, a null, text holding quotes or braces, text opening a list
+// or a comment, or a --- that would end the document.
+func needsQuotes(s string) bool {
+	return s == "" || s == "~" || s == "---" || strings.ContainsAny(s, `"{}`) || strings.HasPrefix(s, "[") || strings.HasPrefix(s, "//")
+}
+
+// formatTime renders t in the configured time format.
+func (e *Encoder) formatTime(t time.Time) string {
+	switch e.timeFormat {
+	case TimeFormatUnix:
+		return strconv.FormatInt(t.Unix(), 10)
+	case TimeFormatUnixMilli:
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	}
+	return e.formatString(t.Format(e.layout()))
+}
+
+// timeCode returns the type code of time columns in the configured format.
+func (e *Encoder) timeCode() string {
+	switch e.timeFormat {
+	case TimeFormatUnix:
+		return "unix"
+	case TimeFormatUnixMilli:
+		return "unixms"
+	}
+	return "time"
+}
+
+// formatFloat renders f with the fewest digits that round-trip at the given
+// bit size, or with the places set by WithFloatPrecision. Integral values
+// keep a trailing ".0" so they still read as floats, unless compact floats
+// are enabled.
+func (e *Encoder) formatFloat(f float64, bits int) string {
+	prec := e.precision()
+	format := byte('f')
+	if abs := math.Abs(f); abs >= 1e21 || (prec < 0 && abs != 0 && abs < 1e-6) {
+		format = 'e'
+	}
+	s := strconv.FormatFloat(f, format, prec, bits)
+	if format == 'e' || math.IsInf(f, 0) || math.IsNaN(f) {
+		return s
+	}
+
+	if e.compactFloats {
+		if strings.Contains(s, ".") {
+			s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+		}
+		return s
+	}
+	// A set precision of 0 means no decimal places; the f0 column type
+	// still marks the cells as floats.
+	if prec < 0 && !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	return s
+}
+
+// formatBytes encodes b as base64, which the decoder assumes for byte
+// values without a type code. Hex is only written in h columns, by rowCells.
+// An empty slice is written as "" so it stays distinct from a nil one.
+func (e *Encoder) formatBytes(b []byte) string {
+	if len(b) == 0 {
+		return `""`
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// deltaEncode rewrites an integer column as its first value followed by the
+// difference between consecutive values. It returns nil when the column has
+// nulls or the deltas would not be shorter than the plain values. Differences
+// wrap on overflow, and the decoder's wrapping sum undoes them exactly.
+func deltaEncode(values []string) []string {
+	if len(values) < 2 {
+		return nil
+	}
+	deltas := make([]string, len(values))
+	var prev int64
+	plainLen, deltaLen := 0, 0
+	for i, v := range values {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil
+		}
+		deltas[i] = strconv.FormatInt(n-prev, 10)
+		prev = n
+		plainLen += len(v)
+		deltaLen += len(deltas[i])
+	}
+	if deltaLen >= plainLen {
+		return nil
+	}
+	return deltas
+}
+
+var (
+	bigIntType        = reflect.TypeOf(big.Int{})
+	bigFloatType      = reflect.TypeOf(big.Float{})
+	timeType          = reflect.TypeOf(time.Time{})
+	durationType      = reflect.TypeOf(time.Duration(0))
+	rawValueType      = reflect.TypeOf(RawValue(nil))
+	marshalerType     = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// maxDepth bounds how deeply values may nest, so a value that refers back
+// to itself, such as a tree whose node points to its parent, fails instead
+// of recursing forever.
+const maxDepth = 1000
+
+func depthError(v reflect.Value) error {
+	return fmt.Errorf("%w: %v nested more than %d levels deep, possibly a cycle", ErrUnsupportedType, v.Type(), maxDepth)
+}
+
+// marshalError carries a failure, such as a MarshalZoon error, up from
+// helpers without an error result to the Encode call that started the write.
+type marshalError struct{ err error }
+
+func catchMarshalError(err *error) {
+	if r := recover(); r != nil {
+		me, ok := r.(marshalError)
+		if !ok {
+			panic(r)
+		}
+		*err = me.err
+	}
+}
+
+// isMarshaler reports whether t, or a pointer to t, implements Marshaler or
+// encoding.TextMarshaler. Times and big numbers are left to their own
+// formatting, which honours the encoder's options.
+func isMarshaler(t reflect.Type) bool {
+	return implements(t, marshalerType) || (implements(t, textMarshalerType) && !hasBuiltinText(t))
+}
+
+// implements reports whether t, or a pointer to t, implements iface.
+func implements(t, iface reflect.Type) bool {
+	return t.Implements(iface) || (t.Kind() != reflect.Ptr && reflect.PointerTo(t).Implements(iface))
+}
+
+// hasBuiltinText reports whether t is a text marshaling type the package
+// formats itself.
+func hasBuiltinText(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t == timeType || isBigType(t)
+}
+
+// marshalerFor returns v as a Marshaler, checking both value and pointer
+// receivers and wrapping an encoding.TextMarshaler when v has no
+// MarshalZoon. Unaddressable values are copied to reach pointer methods.
+func marshalerFor(v reflect.Value) (Marshaler, bool) {
+	if !v.IsValid() || !v.CanInterface() || !isMarshaler(v.Type()) {
+		return nil, false
+	}
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return nil, false
+	}
+	if !v.CanAddr() {
+		p := reflect.New(v.Type())
+		p.Elem().Set(v)
+		v = p.Elem()
+	}
+	for _, c := range []reflect.Value{v, v.Addr()} {
+		if m, ok := c.Interface().(Marshaler); ok {
+			return m, true
+		}
+	}
+	for _, c := range []reflect.Value{v, v.Addr()} {
+		if m, ok := c.Interface().(encoding.TextMarshaler); ok {
+			return textMarshaler{m}, true
+		}
+	}
+	return nil, false
+}
+
+// textMarshaler adapts an encoding.TextMarshaler to Marshaler.
+type textMarshaler struct{ m encoding.TextMarshaler }
+
+func (t textMarshaler) MarshalZoon() ([]byte, error) { return t.m.MarshalText() }
+
+// isBigType reports whether t is big.Int or big.Float, or a pointer to one.
+func isBigType(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t == bigIntType || t == bigFloatType
+}
+
+// formatBig renders a big.Int or big.Float value in decimal. Their methods
+// have pointer receivers, so unaddressable values are copied first.
+func formatBig(v reflect.Value) (string, bool) {
+	if v.Type() != bigIntType && v.Type() != bigFloatType {
+		return "", false
+	}
+	if !v.CanAddr() {
+		p := reflect.New(v.Type()).Elem()
+		p.Set(v)
+		v = p
+	}
+	switch x := v.Addr().Interface().(type) {
+	case *big.Int:
+		return x.String(), true
+	case *big.Float:
+		return x.Text('g', -1), true
+	}
+	return "", false
+}
+
+// valueKind returns the kind used for column type detection, looking
+// through pointers and treating big numbers as their primitive kinds.
+func valueKind(v reflect.Value) reflect.Kind {
+	if t := reflect.Indirect(v).Type(); isMarshaler(v.Type()) || t == timeType || t == durationType {
+		return reflect.String
+	}
+	for v.Kind() == reflect.Ptr && !v.IsNil() && !isBigType(v.Type()) {
+		v = v.Elem()
+	}
+	if isBigType(v.Type()) {
+		t := v.Type()
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t == bigIntType {
+			return reflect.Int
+		}
+		return reflect.Float64
+	}
+	return v.Kind()
+}
+
+// isHoistable reports whether a constant column value can be written as an
+// @name:value header entry. Byte slices stay in a typed column so the decoder
+// knows which encoding to reverse, and inline objects and lists contain
+// spaces.
+func isHoistable(v any) bool {
+	if v == nil {
+		return false
+	}
+	switch t := reflect.TypeOf(v); t.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array:
+		return false
+	}
+	return true
+}
+
+// quote wraps s in double quotes, escaping backslashes and quotes.
+func quote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+func isQuoted(s string) bool {
+	return len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"'
+}
+
+// canBeInt reports whether k holds whole numbers, signed or not.
 func canBeInt(k reflect.Kind) bool {
-	return k == reflect.Int || k == reflect.Int8 || k == reflect.Int16 || k == reflect.Int32 || k == reflect.Int64
+	return isIntKind(k) || isUintKind(k)
 }
 
 func isIntKind(k reflect.Kind) bool {
-	return canBeInt(k)
+	return k == reflect.Int || k == reflect.Int8 || k == reflect.Int16 || k == reflect.Int32 || k == reflect.Int64
+}
+
+func isUintKind(k reflect.Kind) bool {
+	return k == reflect.Uint || k == reflect.Uint8 || k == reflect.Uint16 || k == reflect.Uint32 || k == reflect.Uint64 || k == reflect.Uintptr
+}
+
+func isFloatKind(k reflect.Kind) bool {
+	return k == reflect.Float32 || k == reflect.Float64
+}
+
+func isNumberKind(k reflect.Kind) bool {
+	return canBeInt(k) || isFloatKind(k)
+}
+
+func isByteSlice(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8
 }
 
 func isBoolKind(k reflect.Kind) bool {
@@ -0,0 +1,546 @@
+package zoon
+
+import "time"
+
+// EncoderOption configures an Encoder.
+type EncoderOption interface {
+	applyEncoder(*encoderConfig)
+}
+
+// DecoderOption configures a Decoder.
+type DecoderOption interface {
+	applyDecoder(*decoderConfig)
+}
+
+// Option configures both an Encoder and a Decoder, for settings that must
+// agree on each side of a round trip.
+type Option interface {
+	EncoderOption
+	DecoderOption
+}
+
+type encoderOptionFunc func(*encoderConfig)
+
+func (f encoderOptionFunc) applyEncoder(c *encoderConfig) { f(c) }
+
+type decoderOptionFunc func(*decoderConfig)
+
+func (f decoderOptionFunc) applyDecoder(c *decoderConfig) { f(c) }
+
+type option struct {
+	enc encoderOptionFunc
+	dec decoderOptionFunc
+}
+
+func (o option) applyEncoder(c *encoderConfig) { o.enc(c) }
+func (o option) applyDecoder(c *decoderConfig) { o.dec(c) }
+
+type encoderConfig struct {
+	compactFloats    bool
+	byteEncoding     ByteEncoding
+	inlineMaps       bool
+	objectRows       bool
+	noSpaceEscaping  bool
+	deltaEncoding    bool
+	explicitTypes    bool
+	schema           *Schema
+	boolStyle        BoolStyle
+	unsupportedError bool
+	timeLayout       string
+	timeFormat       TimeFormat
+	columnSeparator  byte
+	noAliases        bool
+	noHoisting       bool
+	minConstantRows  int
+	enumMinOptions   int
+	enumMaxOptions   int
+	enumMaxSet       bool
+	enumMinRatio     float64
+	enumIndexSaving  float64
+	textThreshold    int
+	nullSymbol       string
+	keyLess          func(a, b string) bool
+	intBase          int
+	quoteStrings     bool
+	alignColumns     bool
+	floatPrecision   int
+	floatPrecSet     bool
+}
+
+type decoderConfig struct {
+	noSpaceEscaping bool
+	strict          bool
+	skipBadRows     bool
+	strictFields    bool
+	enumValidation  bool
+	requiredFields  bool
+	timeLayout      string
+	timeFormat      TimeFormat
+	columnSeparator byte
+	nullSymbol      string
+}
+
+// layout returns the configured time layout, defaulting to RFC 3339.
+func (c *encoderConfig) layout() string {
+	if c.timeLayout == "" {
+		return time.RFC3339
+	}
+	return c.timeLayout
+}
+
+// layout returns the configured time layout, defaulting to RFC 3339.
+func (c *decoderConfig) layout() string {
+	if c.timeLayout == "" {
+		return time.RFC3339
+	}
+	return c.timeLayout
+}
+
+// separator returns the byte between the cells of a row.
+func (c *encoderConfig) separator() byte {
+	if c.columnSeparator == 0 {
+		return ' '
+	}
+	return c.columnSeparator
+}
+
+// separator returns the byte between the cells of a row.
+func (c *decoderConfig) separator() byte {
+	if c.columnSeparator == 0 {
+		return ' '
+	}
+	return c.columnSeparator
+}
+
+// lessKey reports whether map key a sorts before b: by the comparator set
+// with WithMapKeyOrder, then lexically among keys it ranks equal.
+func (c *encoderConfig) lessKey(a, b string) bool {
+	if c.keyLess != nil {
+		if c.keyLess(a, b) {
+			return true
+		}
+		if c.keyLess(b, a) {
+			return false
+		}
+	}
+	return a < b
+}
+
+// compareKeys is lessKey as a three-way comparison, for slices.SortFunc.
+func (c *encoderConfig) compareKeys(a, b string) int {
+	switch {
+	case c.lessKey(a, b):
+		return -1
+	case c.lessKey(b, a):
+		return 1
+	}
+	return 0
+}
+
+// constantRows returns the fewest rows a column must span to be hoisted.
+func (c *encoderConfig) constantRows() int {
+	if c.minConstantRows < 2 {
+		return 2
+	}
+	return c.minConstantRows
+}
+
+// maxEnumOptions returns the most distinct values an enum column may have.
+func (c *encoderConfig) maxEnumOptions() int {
+	if !c.enumMaxSet {
+		return 10
+	}
+	return c.enumMaxOptions
+}
+
+// precision returns the decimal places floats are written with, or -1 for
+// the fewest digits that round-trip.
+func (c *encoderConfig) precision() int {
+	if !c.floatPrecSet || c.floatPrecision < 0 {
+		return -1
+	}
+	return c.floatPrecision
+}
+
+// textLength returns the average cell length above which string columns
+// are written as quoted text.
+func (c *encoderConfig) textLength() int {
+	if c.textThreshold <= 0 {
+		return 30
+	}
+	return c.textThreshold
+}
+
+// ByteEncoding selects how []byte values are written.
+type ByteEncoding int
+
+const (
+	// ByteEncodingBase64 writes byte slices as standard base64 under the
+	// base64 type code.
+	ByteEncodingBase64 ByteEncoding = iota
+	// ByteEncodingHex writes byte slices in table columns as lowercase hex
+	// under the h type code. Inline objects and list items, which carry no
+	// type code, keep base64.
+	ByteEncodingHex
+)
+
+// BoolStyle selects how bool values are written. The decoder reads every
+// style, so it needs no matching option.
+type BoolStyle int
+
+const (
+	// BoolStyleDefault writes y/n in inline objects and inferred constants,
+	// and 1/0 in b columns and typed constants.
+	BoolStyleDefault BoolStyle = iota
+	// BoolStyleYesNo writes y/n everywhere.
+	BoolStyleYesNo
+	// BoolStyleTrueFalse writes true/false everywhere.
+	BoolStyleTrueFalse
+	// BoolStyleOneZero writes 1/0 everywhere. Hoisted bool constants carry
+	// the b type code, since a bare 1 would be read as an integer.
+	BoolStyleOneZero
+)
+
+// TimeFormat selects how time.Time values are written.
+type TimeFormat int
+
+const (
+	// TimeFormatRFC3339 writes times as text in the layout set by
+	// WithTimeLayout, RFC 3339 by default, under the time type code.
+	TimeFormatRFC3339 TimeFormat = iota
+	// TimeFormatUnix writes times as whole seconds since the Unix epoch,
+	// under the unix type code.
+	TimeFormatUnix
+	// TimeFormatUnixMilli writes times as milliseconds since the Unix
+	// epoch, under the unixms type code.
+	TimeFormatUnixMilli
+)
+
+// WithCompactFloats strips trailing zeros, and a trailing decimal point,
+// from encoded floats, so 1.50 is written as 1.5 and 2.0 as 2.
+func WithCompactFloats(enabled bool) EncoderOption {
+	return encoderOptionFunc(func(c *encoderConfig) {
+		c.compactFloats = enabled
+	})
+}
+
+// WithByteEncoding selects the representation used for []byte values.
+// The default is ByteEncodingBase64.
+func WithByteEncoding(enc ByteEncoding) EncoderOption {
+	return encoderOptionFunc(func(c *encoderConfig) {
+		c.byteEncoding = enc
+	})
+}
+
+// WithInlineMaps writes map-typed struct fields in tabular output as a single
+// inline object cell per row, such as {env=prod tier=web}, instead of one
+// column per distinct key. This keeps the header small when keys vary
+// between rows.
+func WithInlineMaps(enabled bool) EncoderOption {
+	return encoderOptionFunc(func(c *encoderConfig) {
+		c.inlineMaps = enabled
+	})
+}
+
+// WithObjectRows writes slices as one braced inline object per line instead
+// of a table with a shared header. It suits rows whose keys barely overlap,
+// where a table would be mostly ~ cells.
+func WithObjectRows(enabled bool) EncoderOption {
+	return encoderOptionFunc(func(c *encoderConfig) {
+		c.objectRows = enabled
+	})
+}
+
+// WithDeltaEncoding writes integer columns as deltas, the first value
+// followed by each row's difference from the previous one, under the i^ type
+// code. A column is only delta-encoded when that makes it shorter, which
+// suits monotonic values such as timestamps. Columns with nulls are left as
+// is.
+func WithDeltaEncoding(enabled bool) EncoderOption {
+	return encoderOptionFunc(func(c *encoderConfig) {
+		c.deltaEncoding = enabled
+	})
+}
+
+// WithExplicitTypes writes hoisted constants with a type code, as in
+// @port:i=8080, instead of leaving the decoder to infer the type from the
+// value. Columns always carry a type code, so the whole header becomes
+// self-describing.
+func WithExplicitTypes(enabled bool) EncoderOption {
+	return encoderOptionFunc(func(c *encoderConfig) {
+		c.explicitTypes = enabled
+	})
+}
+
+// WithBoolStyle selects how bool values are written. The default is
+// BoolStyleDefault.
+func WithBoolStyle(style BoolStyle) EncoderOption {
+	return encoderOptionFunc(func(c *encoderConfig) {
+		c.boolStyle = style
+	})
+}
+
+// WithUnsupportedError makes the encoder fail with ErrUnsupportedType on
+// func, chan and unsafe.Pointer values instead of leaving them out.
+func WithUnsupportedError(enabled bool) EncoderOption {
+	return encoderOptionFunc(func(c *encoderConfig) {
+		c.unsupportedError = enabled
+	})
+}
+
+// WithSchema pins the tabular columns described by s, so they are written
+// in schema order with the schema's type codes instead of being detected
+// from each call's rows. Keys the schema does not cover are still detected.
+func WithSchema(s *Schema) EncoderOption {
+	return encoderOptionFunc(func(c *encoderConfig) {
+		c.schema = s
+	})
+}
+
+// WithAliases controls the %a=prefix alias line written for tables whose
+// dotted column names share a prefix. It is on by default.
+func WithAliases(enabled bool) EncoderOption {
+	return encoderOptionFunc(func(c *encoderConfig) {
+		c.noAliases = !enabled
+	})
+}
+
+// WithConstantHoisting controls whether columns holding the same value in
+// every row are moved into the header as @name=value constants. It is on
+// by default.
+func WithConstantHoisting(enabled bool) EncoderOption {
+	return encoderOptionFunc(func(c *encoderConfig) {
+		c.noHoisting = !enabled
+	})
+}
+
+// WithMinConstantRows sets the fewest rows a table must have before its
+// unchanging columns are hoisted as constants. The default, and the
+// smallest useful value, is 2.
+func WithMinConstantRows(n int) EncoderOption {
+	return encoderOptionFunc(func(c *encoderConfig) {
+		c.minConstantRows = n
+	})
+}
+
+// WithEnumMaxOptions sets the most distinct values a string column may hold
+// and still be written as an enum. The default is 10, and 0 turns enum
+// columns off.
+func WithEnumMaxOptions(n int) EncoderOption {
+	return encoderOptionFunc(func(c *encoderConfig) {
+		c.enumMaxOptions, c.enumMaxSet = n, true
+	})
+}
+
+// WithEnumThreshold sets the fewest and the most distinct values a string
+// column may hold and still be written as an enum. The defaults are 1 and
+// 10. Raise max for wide categorical data, or pass a max below min to turn
+// enum columns off.
+func WithEnumThreshold(min, max int) EncoderOption {
+	return encoderOptionFunc(func(c *encoderConfig) {
+		c.enumMinOptions = min
+		c.enumMaxOptions, c.enumMaxSet = max, true
+	})
+}
+
+// WithEnumMaxCardinality sets the most distinct values a string column may
+// hold and still be written as an enum, as WithEnumMaxOptions does. Raise it
+// for wide categorical data, such as the 50 US states.
+func WithEnumMaxCardinality(n int) EncoderOption {
+	return WithEnumMaxOptions(n)
+}
+
+// WithEnumMinCardinality sets the fewest distinct values a string column
+// must hold to be written as an enum. The default is 1.
+func WithEnumMinCardinality(n int) EncoderOption {
+	return encoderOptionFunc(func(c *encoderConfig) {
+		c.enumMinOptions = n
+	})
+}
+
+// WithEnumMinRatio sets how many rows, on average, each distinct value of a
+// string column must fill for the column to be written as an enum. By
+// default any column with a repeated value qualifies.
+func WithEnumMinRatio(ratio float64) EncoderOption {
+	return encoderOptionFunc(func(c *encoderConfig) {
+		c.enumMinRatio = ratio
+	})
+}
+
+// WithIndexedEnumMinSaving sets the share of a column's length that writing
+// its cells as option indexes (!a|b|c) rather than as the values themselves
+// (=a|b|c) must save for the indexed form to be used. The default, 0,
+// indexes whenever it is shorter at all; 1 never indexes.
+func WithIndexedEnumMinSaving(ratio float64) EncoderOption {
+	return encoderOptionFunc(func(c *encoderConfig) {
+		c.enumIndexSaving = ratio
+	})
+}
+
+// WithTextLengthThreshold sets the average cell length, in bytes, above
+// which a string column is written as quoted text under the t type code,
+// keeping its spaces and underscores as is. The default is 30.
+func WithTextLengthThreshold(n int) EncoderOption {
+	return encoderOptionFunc(func(c *encoderConfig) {
+		c.textThreshold = n
+	})
+}
+
+// WithIntBase writes integer columns in base, from 2 to 36, under a type
+// code naming it, as in i36 or u36. Base 36 shortens large ids and hashes
+// by about a third. Other bases, and base 10, leave integers as they are.
+// The decoder reads the base from the header, so it needs no matching
+// option.
+func WithIntBase(base int) EncoderOption {
+	return encoderOptionFunc(func(c *encoderConfig) {
+		c.intBase = base
+	})
+}
+
+// WithMapKeyOrder sets the order in which map keys are written, in inline
+// objects and in table headers, in place of lexical order. less reports
+// whether key a comes before b, and must be a strict weak ordering, as for
+// sort.Slice. Keys it ranks equal are written in lexical order. Table
+// columns and constants are compared by their full dotted names.
+func WithMapKeyOrder(less func(a, b string) bool) EncoderOption {
+	return encoderOptionFunc(func(c *encoderConfig) {
+		c.keyLess = less
+	})
+}
+
+// WithFloatPrecision writes floats with the given number of decimal places,
+// rounding as strconv.FormatFloat does, for output that does not depend on
+// binary representation error such as 0.1+0.2. Float columns are typed
+// f<digits>, as in f2. The default, -1, writes the fewest digits that read
+// back as the same value.
+func WithFloatPrecision(digits int) EncoderOption {
+	return encoderOptionFunc(func(c *encoderConfig) {
+		c.floatPrecision, c.floatPrecSet = digits, true
+	})
+}
+
+// WithQuotedStrings makes the encoder write every string value in double
+// quotes, escaping quotes within, instead of substituting underscores for
+// spaces. Quoted columns are never enums. The decoder reads the output
+// without any option.
+func WithQuotedStrings(enabled bool) EncoderOption {
+	return encoderOptionFunc(func(c *encoderConfig) {
+		c.quoteStrings = enabled
+	})
+}
+
+// WithAlignedColumns pads table cells so each column lines up under its
+// header entry, for output meant to be read by people. Constants and i+
+// columns move to the front of the header line, which has no cells under
+// it. The decoder reads aligned tables as is. It has no effect with a
+// column separator other than a space.
+func WithAlignedColumns(enabled bool) EncoderOption {
+	return encoderOptionFunc(func(c *encoderConfig) {
+		c.alignColumns = enabled
+	})
+}
+
+// WithSpaceEscaping controls the underscore-for-space substitution applied
+// to strings. It is on by default. When off, the encoder quotes strings that
+// contain spaces and leaves underscores as written, and the decoder no longer
+// turns underscores back into spaces. Use the same setting on both sides.
+func WithSpaceEscaping(enabled bool) Option {
+	return option{
+		enc: func(c *encoderConfig) { c.noSpaceEscaping = !enabled },
+		dec: func(c *decoderConfig) { c.noSpaceEscaping = !enabled },
+	}
+}
+
+// WithTimeLayout sets the layout, as understood by time.Time.Format, used to
+// write and parse time.Time values. The default is time.RFC3339. Use the same
+// layout on both sides. It is named for time values, like WithTimeFormat,
+// rather than a bare WithLayout.
+func WithTimeLayout(layout string) Option {
+	return option{
+		enc: func(c *encoderConfig) { c.timeLayout = layout },
+		dec: func(c *decoderConfig) { c.timeLayout = layout },
+	}
+}
+
+// WithTimeFormat selects how time.Time values are written. Table columns
+// record the format in their type code, so the decoder only consults this
+// setting for values without one, such as inline fields. The default is
+// TimeFormatRFC3339.
+func WithTimeFormat(format TimeFormat) Option {
+	return option{
+		enc: func(c *encoderConfig) { c.timeFormat = format },
+		dec: func(c *decoderConfig) { c.timeFormat = format },
+	}
+}
+
+// WithColumnSeparator sets the ASCII character written between the cells of
+// a table row, and split on when reading one, in place of a space. With a
+// separator such as '\t', string cells keep their spaces and underscores as
+// is, and only cells holding the separator are quoted, so rows can be fed
+// to tools like cut and awk. The header line stays space-separated. Use the
+// same separator on both sides.
+func WithColumnSeparator(sep byte) Option {
+	return option{
+		enc: func(c *encoderConfig) { c.columnSeparator = sep },
+		dec: func(c *decoderConfig) { c.columnSeparator = sep },
+	}
+}
+
+// WithNullSymbol sets the token written, and read, for null table cells in
+// place of ~, such as "null" or "-" for tools that expect one. Strings
+// spelled like the symbol are quoted. Use the same symbol on both sides.
+func WithNullSymbol(symbol string) Option {
+	return option{
+		enc: func(c *encoderConfig) { c.nullSymbol = symbol },
+		dec: func(c *decoderConfig) { c.nullSymbol = symbol },
+	}
+}
+
+// WithStrict makes the decoder reject input that lenient decoding would pass
+// through as-is, such as bytes that are not valid UTF-8, enum cells outside
+// their column's declared options, or a field set by both a constant and a
+// column. Bad indexes in ! enum columns are rejected either way. Documents
+// in a legacy encoding should be transcoded to UTF-8 before decoding, for
+// example by wrapping the reader with golang.org/x/text/transform.
+func WithStrict(enabled bool) DecoderOption {
+	return decoderOptionFunc(func(c *decoderConfig) {
+		c.strict = enabled
+	})
+}
+
+// WithEnumValidation makes the decoder fail on cells of = enum columns that
+// are not among the column's declared options, which otherwise are passed
+// through as written. It is the enum check of WithStrict on its own.
+func WithEnumValidation(enabled bool) DecoderOption {
+	return decoderOptionFunc(func(c *decoderConfig) {
+		c.enumValidation = enabled
+	})
+}
+
+// WithRequiredFields makes the decoder fail on rows and inline documents
+// that leave a field tagged required, as in `zoon:"id,required"`, at its zero
+// value, whether its column is missing or its cell is ~. WithStrictFields
+// checks required fields as well.
+func WithRequiredFields(enabled bool) DecoderOption {
+	return decoderOptionFunc(func(c *decoderConfig) {
+		c.requiredFields = enabled
+	})
+}
+
+// WithStrictFields makes the decoder fail on columns and keys that match no
+// field of the destination struct, which otherwise are silently dropped. It
+// catches typos in column names, and enforces fields tagged required.
+func WithStrictFields(enabled bool) DecoderOption {
+	return decoderOptionFunc(func(c *decoderConfig) {
+		c.strictFields = enabled
+	})
+}
+
+// WithSkipBadRows makes tabular decoding drop rows that fail to decode
+// instead of stopping at the first one. The remaining rows are still stored,
+// and Decode returns the row errors joined together with errors.Join.
+func WithSkipBadRows(enabled bool) DecoderOption {
+	return decoderOptionFunc(func(c *decoderConfig) {
+		c.skipBadRows = enabled
+	})
+}
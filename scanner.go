@@ -0,0 +1,227 @@
+package zoon
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+)
+
+// RowScanner decodes a table one row at a time, so the input never has to
+// be held in memory at once. It is created by Decoder.Scanner and used like
+// bufio.Scanner:
+//
+//	sc := zoon.NewDecoder(r).Scanner(Event{})
+//	for sc.Scan() {
+//		ev := sc.Row().(Event)
+//		...
+//	}
+//	if err := sc.Err(); err != nil {
+//		...
+//	}
+type RowScanner struct {
+	d       *Decoder
+	br      *bufio.Reader
+	readErr error
+	blanks  int          // consecutive blank lines just read
+	typ     reflect.Type // type of the values Row returns
+	rd      *rowDecoder
+	pending int // rows implied by +N, still to be returned
+	line    int
+	rowNum  int
+	row     any
+	err     error
+	rowErrs []error
+	done    bool
+}
+
+// Scanner returns a RowScanner that reads a table from d's input and decodes
+// each row into a value of prototype's type. prototype may be a value, such
+// as Event{}, or a pointer, such as &Event{}; Row returns the same kind.
+// Scanning stops at the end of the current document, leaving any that
+// follow to d.
+func (d *Decoder) Scanner(prototype any) *RowScanner {
+	s := &RowScanner{d: d, br: d.reader()}
+	if d.peeked {
+		doc, err := d.readDocument()
+		s.br, s.readErr = bufio.NewReader(bytes.NewReader(doc)), err
+	}
+	if prototype == nil {
+		s.err = fmt.Errorf("%w: Scanner needs a prototype value", ErrUnsupportedType)
+		s.done = true
+		return s
+	}
+	s.typ = reflect.TypeOf(prototype)
+	return s
+}
+
+// Scan decodes the next row, which is then available through Row. It
+// returns false when the input is exhausted or an error occurs. With
+// WithSkipBadRows, rows that fail to decode are skipped and their errors
+// reported by Err once scanning stops.
+func (s *RowScanner) Scan() bool {
+	if s.done {
+		return false
+	}
+	if s.rd == nil && !s.start() {
+		return false
+	}
+
+	for {
+		var vals []string
+		var line string
+		if s.pending > 0 {
+			s.pending--
+		} else {
+			var ok bool
+			if line, ok = s.nextLine(); !ok {
+				return s.stop(nil)
+			}
+			vals = tokenizeRow(line, string(s.d.separator()))
+		}
+
+		s.rowNum++
+		row, err := s.decode(vals, line)
+		if err == nil {
+			s.row = row
+			return true
+		}
+		err = fmt.Errorf("%w in row %d", err, s.rowNum)
+		if !s.d.skipBadRows {
+			return s.stop(err)
+		}
+		s.rowErrs = append(s.rowErrs, err)
+	}
+}
+
+// Row returns the row decoded by the last successful call to Scan.
+func (s *RowScanner) Row() any {
+	return s.row
+}
+
+// Err returns the error that stopped scanning, or nil at a clean end of
+// input.
+func (s *RowScanner) Err() error {
+	return s.err
+}
+
+// start reads the header on the first call to Scan.
+func (s *RowScanner) start() bool {
+	hdr := s.d.takeHeader()
+	if hdr == nil {
+		var err error
+		if hdr, err = readHeader(&scanLines{s: s}); err != nil {
+			return s.stop(err)
+		}
+	}
+	s.d.metadata = hdr.metadata
+	if s.d.strict {
+		if err := hdr.checkConflicts(); err != nil {
+			return s.stop(err)
+		}
+	}
+	elemType := s.typ
+	if elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+	s.rd = newRowDecoder(s.d, hdr, elemType)
+	s.pending = max(hdr.rows, 0)
+	return true
+}
+
+// nextLine returns the next non-blank line of the document.
+func (s *RowScanner) nextLine() (string, bool) {
+	for s.readErr == nil {
+		raw, err := s.br.ReadString('\n')
+		if err != nil {
+			if err != io.EOF {
+				s.readErr = err
+				return "", false
+			}
+			if raw == "" {
+				return "", false
+			}
+		}
+		s.line++
+		if s.d.strict && !utf8.ValidString(raw) {
+			s.err = fmt.Errorf("%w: invalid UTF-8 at line %d", ErrInvalidFormat, s.line)
+			return "", false
+		}
+		line := strings.TrimSpace(raw)
+		if line == "" {
+			// Two blank lines end the document, once it has a header.
+			if s.blanks++; s.blanks >= 2 && s.rd != nil {
+				return "", false
+			}
+			continue
+		}
+		s.blanks = 0
+		if line == "---" {
+			if s.rd != nil {
+				return "", false
+			}
+			continue
+		}
+		if !isComment(line) {
+			return line, true
+		}
+	}
+	return "", false
+}
+
+func (s *RowScanner) decode(vals []string, line string) (any, error) {
+	elem, null, err := s.rd.decode(vals, line)
+	if err != nil {
+		return nil, err
+	}
+	if s.typ.Kind() != reflect.Ptr {
+		return elem.Interface(), nil
+	}
+	if null {
+		return reflect.Zero(s.typ).Interface(), nil
+	}
+	ptr := reflect.New(s.typ.Elem())
+	ptr.Elem().Set(elem)
+	return ptr.Interface(), nil
+}
+
+// stop ends scanning with err, joined with any skipped row errors.
+func (s *RowScanner) stop(err error) bool {
+	s.done = true
+	s.row = nil
+	if s.err == nil {
+		s.err = err
+	}
+	if s.err == nil {
+		s.err = s.readErr
+	}
+	if len(s.rowErrs) > 0 {
+		s.err = errors.Join(append(s.rowErrs, s.err)...)
+	}
+	return false
+}
+
+// scanLines feeds a RowScanner's lines to readHeader.
+type scanLines struct {
+	s    *RowScanner
+	line string
+}
+
+func (l *scanLines) Scan() bool {
+	var ok bool
+	l.line, ok = l.s.nextLine()
+	return ok
+}
+
+func (l *scanLines) Text() string { return l.line }
+
+func (l *scanLines) Err() error {
+	if l.s.err != nil {
+		return l.s.err
+	}
+	return l.s.readErr
+}
@@ -0,0 +1,210 @@
+package zoon
+
+import (
+	"bufio"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Schema describes the header of a table. Deriving one once from a
+// representative sample and encoding with MarshalWith or WithSchema gives
+// every batch of the same type the same header, without detecting column
+// types from each batch's rows. ParseSchema reads one from header lines,
+// for tools that work with headers as values.
+//
+// Encoding pins only the Columns; the aliases, constants and row count of a
+// parsed header describe that one table.
+type Schema struct {
+	Aliases   map[string]string // alias to name prefix, as in %a=addr
+	Constants []ColumnDef
+	Columns   []ColumnDef
+	RowCount  int // the +N row count, or 0 when none is given
+}
+
+// ColumnDef describes one column or constant of a table header, in a Schema
+// or as returned by Decoder.DecodeHeader.
+type ColumnDef struct {
+	Name       string   // dotted field path, such as "user.name"
+	TypeCode   string   // header type code, such as s, i, f or b
+	Indexed    bool     // enum values are written as option indexes
+	Options    []string // enum options, in header order
+	IsAutoInc  bool     // an i+ column, numbered on from its start with no cells
+	IsConstant bool     // a hoisted @name constant rather than a column
+	Value      string   // a constant's value, or an i+ column's start if not 1
+}
+
+// ParseSchema parses the alias lines and the # header line of a table.
+// Lines after the header are ignored. Constants written without a type code
+// have an empty TypeCode.
+func ParseSchema(headerLines []string) (*Schema, error) {
+	scanner := bufio.NewScanner(strings.NewReader(strings.Join(headerLines, "\n")))
+	hdr, err := readHeader(scanner)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Schema{RowCount: max(hdr.rows, 0)}
+	if len(hdr.aliases) > 0 {
+		s.Aliases = hdr.aliases
+	}
+	s.Constants, s.Columns = hdr.defs()
+	return s, nil
+}
+
+// Validate checks a data row, split into cells, against the schema's
+// columns: the cell count, enum options and that each typed cell parses.
+// i+ columns take no cell.
+func (s *Schema) Validate(row []string) error {
+	var cols []ColumnDef
+	for _, c := range s.Columns {
+		if !c.IsAutoInc {
+			cols = append(cols, c)
+		}
+	}
+	if len(row) != len(cols) {
+		return fmt.Errorf("%w: row has %d cells, schema has %d columns", ErrInvalidFormat, len(row), len(cols))
+	}
+
+	d := &Decoder{}
+	for i, c := range cols {
+		cell := row[i]
+		if cell == "~" {
+			continue
+		}
+		switch {
+		case len(c.Options) > 0:
+			if c.Indexed {
+				if n, err := strconv.Atoi(cell); err != nil || n < 0 || n >= len(c.Options) {
+					return fmt.Errorf("%w: column %s: %q is not an option index", ErrInvalidFormat, c.Name, cell)
+				}
+			} else if !slices.Contains(c.Options, cell) {
+				return fmt.Errorf("%w: column %s: %q is not an option", ErrInvalidFormat, c.Name, cell)
+			}
+		case strings.HasSuffix(c.TypeCode, "[]"):
+			if !strings.HasPrefix(cell, "[") || !strings.HasSuffix(cell, "]") {
+				return fmt.Errorf("%w: column %s: %q is not a list", ErrInvalidFormat, c.Name, cell)
+			}
+		default:
+			// Delta cells are plain integers.
+			if _, err := d.parsePrimitive(cell, strings.TrimSuffix(c.TypeCode, "^")); err != nil {
+				return fmt.Errorf("%w: column %s: %v", ErrInvalidFormat, c.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// DeriveSchema derives a schema from sample, a slice of structs or maps,
+// choosing each column's type as Marshal would. No column is hoisted to a
+// constant or written as an i+ sequence, since later batches may differ.
+func DeriveSchema(sample any) (_ *Schema, err error) {
+	defer catchMarshalError(&err)
+	val := reflect.Indirect(reflect.ValueOf(sample))
+	if val.Kind() != reflect.Slice && val.Kind() != reflect.Array {
+		return nil, fmt.Errorf("%w: DeriveSchema needs a slice, got %T", ErrUnsupportedType, sample)
+	}
+
+	e := &Encoder{}
+	rows := e.flattenRows(val)
+	keySet := make(map[string]bool)
+	for _, row := range rows {
+		for k := range row {
+			keySet[k] = true
+		}
+	}
+	var keys []string
+	for k := range keySet {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	s := &Schema{}
+	for _, k := range keys {
+		st := e.collectStats(k, rows, true)
+		e.chooseTypeCode(st, len(rows))
+		def := ColumnDef{Name: k, TypeCode: st.typeCode}
+		if len(st.enumKeys) > 0 {
+			def.TypeCode, def.Indexed, def.Options = "s", st.indexed, st.enumKeys
+		}
+		s.Columns = append(s.Columns, def)
+	}
+	return s, nil
+}
+
+// String returns the schema as a ZOON header: a line of alias definitions,
+// if it has aliases, and the # header line.
+func (s *Schema) String() string {
+	var lines []string
+	prefixes := make(map[string]string, len(s.Aliases))
+	if len(s.Aliases) > 0 {
+		var parts []string
+		for alias, prefix := range s.Aliases {
+			parts = append(parts, "%"+alias+"="+prefix)
+			prefixes[prefix] = alias
+		}
+		sort.Strings(parts)
+		lines = append(lines, strings.Join(parts, " "))
+	}
+
+	parts := []string{"#"}
+	for _, c := range s.Constants {
+		name := "@" + headerName(applyAlias(c.Name, prefixes))
+		switch c.TypeCode {
+		case "":
+			parts = append(parts, name+":"+c.Value)
+		case "s":
+			parts = append(parts, name+"="+c.Value)
+		default:
+			parts = append(parts, name+":"+c.TypeCode+"="+c.Value)
+		}
+	}
+	for _, c := range s.Columns {
+		parts = append(parts, headerName(applyAlias(c.Name, prefixes))+c.code())
+	}
+	if s.RowCount > 0 {
+		parts = append(parts, fmt.Sprintf("+%d", s.RowCount))
+	}
+	return strings.Join(append(lines, strings.Join(parts, " ")), "\n")
+}
+
+// code returns the column's header suffix, such as :i or =a|b.
+func (c ColumnDef) code() string {
+	if c.IsAutoInc {
+		return ":i+" + c.Value
+	}
+	if len(c.Options) > 0 {
+		sep := "="
+		if c.Indexed {
+			sep = "!"
+		}
+		return sep + strings.Join(c.Options, "|")
+	}
+	return ":" + c.TypeCode
+}
+
+// stats returns the column as the encoder's pinned column stats. An i+
+// column is written as plain i, as batches need not share its start.
+func (c ColumnDef) stats() *columnStats {
+	c.IsAutoInc = false
+	st := &columnStats{name: c.Name, typeCode: strings.TrimPrefix(c.code(), ":")}
+	switch {
+	case len(c.Options) > 0:
+		st.enumKeys, st.indexed = c.Options, c.Indexed
+	case c.TypeCode == "b":
+		st.kind = reflect.Bool
+	case c.TypeCode == "t":
+		st.isText = true
+	case c.TypeCode == "h", c.TypeCode == "base64":
+		st.isBytes = true
+	case c.TypeCode == "i^":
+		st.pinnedSum = new(int64)
+	}
+	if base, ok := intBase(c.TypeCode); ok {
+		st.intBase = base
+	}
+	return st
+}
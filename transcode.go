@@ -0,0 +1,377 @@
+package zoon
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// TranscodeOption configures TranscodeFromJSON and TranscodeToJSON.
+type TranscodeOption interface {
+	applyTranscode(*transcodeConfig)
+}
+
+// transcodeConfig is empty for now; it keeps the transcoders' signatures
+// stable as options are added.
+type transcodeConfig struct{}
+
+// TranscodeFromJSON reads a JSON object or array from r and writes it to w
+// as ZOON. Arrays of objects become tables and objects become inline
+// documents. Numbers written with a decimal point or exponent become floats
+// and the rest integers, so columns keep the i or f type they had in JSON;
+// booleans become b and nulls ~.
+func TranscodeFromJSON(r io.Reader, w io.Writer, options ...TranscodeOption) error {
+	var cfg transcodeConfig
+	for _, opt := range options {
+		opt.applyTranscode(&cfg)
+	}
+
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+
+	switch x := jsonValue(v).(type) {
+	case map[string]any:
+		return NewEncoder(w).Encode(x)
+	case []any:
+		rows := make([]map[string]any, 0, len(x))
+		for _, el := range x {
+			m, ok := el.(map[string]any)
+			if !ok {
+				// Not a table; write the array as a primitive list.
+				return NewEncoder(w).Encode(x)
+			}
+			rows = append(rows, m)
+		}
+		return NewEncoder(w).Encode(rows)
+	default:
+		return fmt.Errorf("%w: top level JSON must be object or array, got %T", ErrUnsupportedType, x)
+	}
+}
+
+// TranscodeToJSON reads a ZOON document from r and writes it to w as JSON.
+// Tables and object rows become arrays of objects, a primitive list an array
+// of values, and an inline document a single object.
+//
+// Tables are streamed a row at a time. Cells take the JSON type of their
+// column's type code, constants appear in every object, i+ columns are
+// numbered and ~ cells become null. Durations, times and hex bytes are
+// written as the strings they were in the input.
+func TranscodeToJSON(r io.Reader, w io.Writer, options ...TranscodeOption) error {
+	var cfg transcodeConfig
+	for _, opt := range options {
+		opt.applyTranscode(&cfg)
+	}
+
+	br := bufio.NewReader(r)
+	head, isTable, err := readLeadingLines(br)
+	if err != nil {
+		return err
+	}
+	src := io.MultiReader(bytes.NewReader(head), br)
+	if isTable {
+		return tableToJSON(NewDecoder(src), w)
+	}
+
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return err
+	}
+	var v any
+	switch trimmed := bytes.TrimSpace(skipComments(data)); {
+	case len(trimmed) == 0:
+		v = nil
+	case trimmed[0] == '{':
+		var rows []map[string]any
+		if err := Unmarshal(data, &rows); err != nil {
+			return err
+		}
+		v = rows
+	default:
+		var obj map[string]any
+		if err := Unmarshal(data, &obj); err != nil {
+			return err
+		}
+		v = obj
+	}
+	return json.NewEncoder(w).Encode(v)
+}
+
+// readLeadingLines reads the blank, comment and metadata lines at the start
+// of br and the line after them, and reports whether that line opens a
+// table. The lines read are returned for decoding.
+func readLeadingLines(br *bufio.Reader) (head []byte, isTable bool, err error) {
+	for {
+		line, err := br.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, false, err
+		}
+		head = append(head, line...)
+		trimmed := strings.TrimSpace(string(line))
+		switch {
+		case trimmed == "" || isComment(trimmed):
+		case trimmed[0] == '#' || trimmed[0] == '%':
+			return head, true, nil
+		default:
+			if _, _, ok := metadataLine(trimmed); !ok {
+				return head, false, nil
+			}
+		}
+		if err == io.EOF {
+			return head, false, nil
+		}
+	}
+}
+
+// tableToJSON writes the table read by dec as a JSON array, encoding one row
+// at a time.
+func tableToJSON(dec *Decoder, w io.Writer) error {
+	fields, err := dec.DecodeHeader()
+	if err != nil {
+		return err
+	}
+	list := len(fields) == 1 && fields[0].Name == "" && !fields[0].IsConstant
+	hdr := dec.header
+	for i := range hdr.constants {
+		hdr.constants[i].Type = jsonTypeCode(hdr.constants[i].Type)
+	}
+	for i := range hdr.columns {
+		hdr.columns[i].Type = jsonTypeCode(hdr.columns[i].Type)
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	buf.WriteByte('[')
+	sc := dec.Scanner(map[string]any{})
+	for n := 0; sc.Scan(); n++ {
+		if n > 0 {
+			buf.WriteByte(',')
+		}
+		row := sc.Row().(map[string]any)
+		var v any = row
+		if list {
+			v = row[""]
+		} else {
+			for _, f := range fields {
+				setNull(row, f.Name)
+			}
+		}
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		// Encode ends each value with a newline, which stays out of the array.
+		buf.Truncate(buf.Len() - 1)
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			return err
+		}
+		buf.Reset()
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	buf.WriteString("]\n")
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// jsonTypeCode returns the type code a column is decoded under for JSON
+// output. Durations, times and hex bytes are kept as the text written in the
+// input, rather than becoming nanoseconds, Go's time format or base64, and
+// epoch times stay numbers.
+func jsonTypeCode(typ string) string {
+	elem, list := strings.CutSuffix(typ, "[]")
+	switch elem {
+	case "dur", "time", "h":
+		elem = "s"
+	case "unix", "unixms":
+		elem = "i"
+	default:
+		return typ
+	}
+	if list {
+		return elem + "[]"
+	}
+	return elem
+}
+
+// setNull stores nil at the dotted path name in row unless a value is
+// already there, creating the objects along the way, so ~ cells are written
+// as JSON nulls rather than left out.
+func setNull(row map[string]any, name string) {
+	parts := strings.Split(name, ".")
+	for _, p := range parts[:len(parts)-1] {
+		next, ok := row[p].(map[string]any)
+		if !ok {
+			if row[p] != nil {
+				return
+			}
+			next = make(map[string]any)
+			row[p] = next
+		}
+		row = next
+	}
+	if _, ok := row[parts[len(parts)-1]]; !ok {
+		row[parts[len(parts)-1]] = nil
+	}
+}
+
+// jsonValue replaces the json.Number values in v, decoded with UseNumber,
+// with an int64 or float64 depending on how the number was written.
+func jsonValue(v any) any {
+	switch x := v.(type) {
+	case json.Number:
+		s := x.String()
+		if !strings.ContainsAny(s, ".eE") {
+			if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+				return n
+			}
+			if u, err := strconv.ParseUint(s, 10, 64); err == nil {
+				return u
+			}
+		}
+		f, _ := x.Float64()
+		return f
+	case map[string]any:
+		for k, el := range x {
+			x[k] = jsonValue(el)
+		}
+	case []any:
+		for i, el := range x {
+			x[i] = jsonValue(el)
+		}
+	}
+	return v
+}
+
+// csvSampleRows is how many rows TranscodeFromCSV inspects to pick a type
+// for each column.
+const csvSampleRows = 100
+
+// CSVOption configures TranscodeFromCSV.
+type CSVOption interface {
+	applyCSV(*csvConfig)
+}
+
+type csvConfig struct {
+	comma rune
+}
+
+type csvOptionFunc func(*csvConfig)
+
+func (f csvOptionFunc) applyCSV(c *csvConfig) { f(c) }
+
+// WithCSVComma sets the field delimiter of the CSV input. The default is ','.
+func WithCSVComma(comma rune) CSVOption {
+	return csvOptionFunc(func(c *csvConfig) {
+		c.comma = comma
+	})
+}
+
+// TranscodeFromCSV reads a CSV document with a header row from r and writes
+// it to w as a ZOON table. Each column's type is inferred from its first 100
+// non-empty cells: integers if they all parse as such, then floats, then
+// bools written as true/false/1/0, and strings otherwise. Empty cells in typed
+// columns become ~. The rows are then encoded like any other table, so
+// constant columns are hoisted and enums detected.
+func TranscodeFromCSV(r io.Reader, w io.Writer, opts ...CSVOption) error {
+	cfg := csvConfig{comma: ','}
+	for _, opt := range opts {
+		opt.applyCSV(&cfg)
+	}
+
+	cr := csv.NewReader(r)
+	cr.Comma = cfg.comma
+	records, err := cr.ReadAll()
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return fmt.Errorf("%w: CSV has no header row", ErrInvalidFormat)
+	}
+	header, records := records[0], records[1:]
+
+	kinds := make([]reflect.Kind, len(header))
+	for col := range header {
+		kinds[col] = inferCSVKind(records, col)
+	}
+
+	rows := make([]map[string]any, len(records))
+	for i, rec := range records {
+		rows[i] = make(map[string]any, len(header))
+		for col, name := range header {
+			rows[i][name] = csvValue(rec[col], kinds[col])
+		}
+	}
+	return NewEncoder(w).Encode(rows)
+}
+
+// inferCSVKind returns the kind of column col, judged from its non-empty
+// cells in the first csvSampleRows records.
+func inferCSVKind(records [][]string, col int) reflect.Kind {
+	isInt, isFloat, isBool := true, true, true
+	seen := false
+	for _, rec := range records[:min(len(records), csvSampleRows)] {
+		cell := rec[col]
+		if cell == "" {
+			continue
+		}
+		seen = true
+		if _, err := strconv.ParseInt(cell, 10, 64); err != nil {
+			isInt = false
+		}
+		if _, err := strconv.ParseFloat(cell, 64); err != nil {
+			isFloat = false
+		}
+		switch cell {
+		case "true", "false", "1", "0":
+		default:
+			isBool = false
+		}
+	}
+	switch {
+	case !seen:
+		return reflect.String
+	case isInt:
+		return reflect.Int64
+	case isFloat:
+		return reflect.Float64
+	case isBool:
+		return reflect.Bool
+	}
+	return reflect.String
+}
+
+// csvValue converts a cell to the column's inferred kind. Cells past the
+// sample that don't fit stay strings, which turns the column into s.
+func csvValue(cell string, kind reflect.Kind) any {
+	if kind == reflect.String {
+		return cell
+	}
+	if cell == "" {
+		return nil
+	}
+	switch kind {
+	case reflect.Int64:
+		if n, err := strconv.ParseInt(cell, 10, 64); err == nil {
+			return n
+		}
+	case reflect.Float64:
+		if f, err := strconv.ParseFloat(cell, 64); err == nil {
+			return f
+		}
+	case reflect.Bool:
+		if b, err := parseBool(cell); err == nil {
+			return b
+		}
+	}
+	return cell
+}
@@ -1,39 +1,206 @@
 package zoon
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"errors"
+	"fmt"
 	"io"
+	"reflect"
 )
 
+// Marshaler is implemented by types that write their own ZOON value, in
+// place of the reflective encoding of their fields. The returned bytes are
+// written as a single string token: spaces are escaped and quotes added
+// where needed, as for any string, so MarshalZoon returns the raw text.
+type Marshaler interface {
+	MarshalZoon() ([]byte, error)
+}
+
+// Unmarshaler is implemented by types that read their own ZOON value. It
+// receives the token written by MarshalZoon.
+type Unmarshaler interface {
+	UnmarshalZoon([]byte) error
+}
+
+// RawValue is a ZOON value kept exactly as written, such as a number, a
+// quoted string or a {...} object. As a field type it lets decoding of
+// that field be deferred, much like json.RawMessage: the decoder stores the
+// token untouched and the encoder writes it back verbatim, so it must be a
+// single valid token. An empty RawValue is written as ~.
+type RawValue []byte
+
 // Encoder writes ZOON format to an output stream.
 type Encoder struct {
-	w io.Writer
+	w     io.Writer
+	out   *docWriter // e.w as given to NewEncoder, for document separators
+	depth int        // levels of inline objects enclosing the value written
+	encoderConfig
+}
+
+// NewEncoder returns a new encoder that writes to w, configured by opts.
+func NewEncoder(w io.Writer, opts ...EncoderOption) *Encoder {
+	out := &docWriter{w: w}
+	e := &Encoder{w: out, out: out}
+	for _, opt := range opts {
+		opt.applyEncoder(&e.encoderConfig)
+	}
+	return e
 }
 
-// NewEncoder returns a new encoder that writes to w.
-func NewEncoder(w io.Writer) *Encoder {
-	return &Encoder{w: w}
+// Encode writes the encoding of each of docs to the stream. Every value is a
+// document of its own; from the second document written by e on, each is
+// preceded by a --- separator line, which Decoder.More and Decode use to
+// read them back one at a time.
+func (e *Encoder) Encode(docs ...any) error {
+	for _, v := range docs {
+		if err := e.EncodeContext(context.Background(), v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EncodeContext is like Encode for a single value, but stops with ctx's
+// error once ctx is done, checking after each row written. Rows already
+// written stay in the stream.
+func (e *Encoder) EncodeContext(ctx context.Context, v any) (err error) {
+	defer catchMarshalError(&err)
+	if err := e.startDocument(); err != nil {
+		return err
+	}
+	return e.encode(ctx, v)
 }
 
-// Encode writes the encoding of v to the stream.
-func (e *Encoder) Encode(v any) error {
-	return e.encode(v)
+// EncodeChan drains ch, a channel of structs or maps, and writes the received
+// elements as one table without collecting them first. It returns once ch is
+// closed, writing nothing if no element was received.
+//
+// The header is derived from the first element alone: there are no hoisted
+// constants, enum columns or i+ sequences, aliases come from the first
+// element's keys, and keys that only appear in later elements are dropped.
+func (e *Encoder) EncodeChan(ch any) (err error) {
+	defer catchMarshalError(&err)
+	if err := e.startDocument(); err != nil {
+		return err
+	}
+	cv := reflect.ValueOf(ch)
+	if cv.Kind() != reflect.Chan || cv.Type().ChanDir()&reflect.RecvDir == 0 {
+		return fmt.Errorf("%w: EncodeChan needs a receivable channel, got %T", ErrUnsupportedType, ch)
+	}
+	return e.encodeChan(cv)
 }
 
 // Decoder reads ZOON values from an input stream.
 type Decoder struct {
-	r io.Reader
+	r        io.Reader
+	header   *tableHeader // read by DecodeHeader, for the next decode
+	next     []byte       // document read ahead by More
+	nextErr  error
+	peeked   bool
+	funcs    map[reflect.Type]func(token string) (any, error)
+	metadata map[string]string // metadata lines of the last header read
+	decoderConfig
+}
+
+// NewDecoder returns a new decoder that reads from r, configured by opts.
+func NewDecoder(r io.Reader, opts ...DecoderOption) *Decoder {
+	d := &Decoder{r: r}
+	for _, opt := range opts {
+		opt.applyDecoder(&d.decoderConfig)
+	}
+	return d
+}
+
+// Reset makes d read from r, keeping its options, and drops any header read
+// by DecodeHeader.
+func (d *Decoder) Reset(r io.Reader) {
+	d.r = r
+	d.header, d.metadata = nil, nil
+	d.next, d.nextErr, d.peeked = nil, nil, false
+}
+
+// DisallowUnknownFields makes d fail on columns and keys that match no field
+// of the destination struct, like WithStrictFields(true). Map destinations
+// accept any key.
+func (d *Decoder) DisallowUnknownFields() {
+	d.strictFields = true
+}
+
+// RegisterDecodeFunc makes d decode values of type t with fn instead of the
+// built-in rules, for types that cannot implement Unmarshaler. fn receives
+// each non-null token unquoted, with escaped spaces restored, and returns a
+// value assignable or convertible to t. A nil fn removes the registration.
+func (d *Decoder) RegisterDecodeFunc(t reflect.Type, fn func(token string) (any, error)) {
+	if fn == nil {
+		delete(d.funcs, t)
+		return
+	}
+	if d.funcs == nil {
+		d.funcs = make(map[reflect.Type]func(string) (any, error))
+	}
+	d.funcs[t] = fn
+}
+
+// Metadata returns the key:value lines that preceded the header of the
+// table last decoded, such as "source: crm export", or nil if there were
+// none. Metadata lines may come before or between alias lines, and end at
+// the # header line.
+func (d *Decoder) Metadata() map[string]string {
+	return d.metadata
+}
+
+// More reports whether another document follows in the input. Documents are
+// separated by a --- line or by two consecutive blank lines.
+func (d *Decoder) More() bool {
+	if !d.peeked {
+		d.next, d.nextErr = d.readDocument()
+		d.peeked = true
+	}
+	// An error is left for the next Decode to return.
+	return d.nextErr != nil || hasContent(d.next)
 }
 
-// NewDecoder returns a new decoder that reads from r.
-func NewDecoder(r io.Reader) *Decoder {
-	return &Decoder{r: r}
+// DecodeHeader reads the alias and # header lines of a table and returns its
+// constants and columns, in header order. The input is left at the first
+// row, and the next Decode or Scanner call decodes the rows against this
+// header.
+func (d *Decoder) DecodeHeader() ([]ColumnDef, error) {
+	br := d.reader()
+	if d.peeked {
+		// The document is already read; take the header from it.
+		if d.nextErr != nil {
+			return nil, d.nextErr
+		}
+		br = bufio.NewReader(bytes.NewReader(d.next))
+	}
+	hdr, err := readHeader(&readerLines{r: br})
+	if err != nil {
+		return nil, err
+	}
+	if d.peeked {
+		d.next, _ = io.ReadAll(br)
+	}
+	d.header = hdr
+	d.metadata = hdr.metadata
+
+	constants, columns := hdr.defs()
+	return append(constants, columns...), nil
 }
 
-// Decode reads the next JSON-encoded value from its input and stores it in the value pointed to by v.
+// Decode reads the next document from its input and stores it in the value
+// pointed to by v. It returns io.EOF once the input holds no more documents.
+// Tables and object rows end at a --- line or two blank lines, and inline
+// objects also at a single blank line.
 func (d *Decoder) Decode(v any) error {
-	return d.decode(v)
+	return d.DecodeContext(context.Background(), v)
+}
+
+// DecodeContext is like Decode but stops with ctx's error once ctx is done,
+// checking before each row is decoded.
+func (d *Decoder) DecodeContext(ctx context.Context, v any) error {
+	return d.decode(ctx, v)
 }
 
 // Marshal returns the ZOON encoding of v.
@@ -45,9 +212,104 @@ func Marshal(v any) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// MarshalWith returns the ZOON encoding of v with its table columns pinned
+// by schema. See WithSchema.
+func MarshalWith(v any, schema *Schema) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf, WithSchema(schema)).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// MarshalIndent is like Marshal but aligns the columns of tables, as with
+// WithAlignedColumns, for reading. The output decodes like Marshal's.
+func MarshalIndent(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf, WithAlignedColumns(true)).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// MarshalPaged returns the ZOON encoding of the slice or array v split into
+// pages of at most rowsPerPage rows. Each page is a document of its own, with
+// a header derived from its rows alone, so it decodes into []T without the
+// others. An empty v gives no pages.
+func MarshalPaged(v any, rowsPerPage int) ([][]byte, error) {
+	if rowsPerPage < 1 {
+		return nil, fmt.Errorf("zoon: MarshalPaged needs at least 1 row per page, got %d", rowsPerPage)
+	}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Slice:
+	case reflect.Array:
+		// Slicing needs an addressable array.
+		arr := reflect.New(rv.Type()).Elem()
+		arr.Set(rv)
+		rv = arr
+	default:
+		return nil, fmt.Errorf("%w: MarshalPaged needs a slice or array, got %T", ErrUnsupportedType, v)
+	}
+
+	var pages [][]byte
+	for i := 0; i < rv.Len(); i += rowsPerPage {
+		page, err := Marshal(rv.Slice(i, min(i+rowsPerPage, rv.Len())).Interface())
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, page)
+	}
+	return pages, nil
+}
+
 // Unmarshal parses the ZOON-encoded data and stores the result in the value pointed to by v.
+// Empty data leaves v unchanged.
 func Unmarshal(data []byte, v any) error {
-	return NewDecoder(bytes.NewReader(data)).Decode(v)
+	if err := NewDecoder(bytes.NewReader(data)).Decode(v); err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+// DecodeInto parses the ZOON-encoded data and stores the result in rv, which
+// must be settable, such as a struct field reached through a pointer. It lets
+// other codecs hand a value they already hold to zoon without taking its
+// address first.
+func DecodeInto(data []byte, rv reflect.Value) error {
+	if !rv.IsValid() {
+		return fmt.Errorf("%w: DecodeInto(invalid value)", ErrUnsupportedType)
+	}
+	if err := NewDecoder(bytes.NewReader(data)).decodeValue(context.Background(), rv); err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+// Valid reports whether data holds one or more well-formed ZOON documents.
+// It checks structure only, such as headers and balanced quotes and braces,
+// without decoding any values, so a valid document may still fail to decode
+// into a given type.
+func Valid(data []byte) bool {
+	d := NewDecoder(bytes.NewReader(data))
+	docs := 0
+	for {
+		doc, err := d.readDocument()
+		if err != nil {
+			return false
+		}
+		switch err := checkStructure(doc); err {
+		case nil:
+			docs++
+		case io.EOF:
+			return docs > 0
+		default:
+			return false
+		}
+	}
 }
 
 var (
@@ -1,9 +1,23 @@
 package zoon
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"net"
+	"net/netip"
 	"reflect"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 type User struct {
@@ -208,8 +222,43 @@ func TestSpecialCharacters(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if dec[0].Text != "Hello World" {
-		t.Errorf("Underscore not restored: %q", dec[0].Text)
+	if !reflect.DeepEqual(data, dec) {
+		t.Errorf("Roundtrip mismatch.\nOriginal: %q\nDecoded: %q", data, dec)
+	}
+}
+
+func TestLiteralUnderscores(t *testing.T) {
+	type Data struct {
+		Text string `zoon:"text"`
+	}
+
+	data := []Data{{"Hello_World"}, {"Hello World"}, {"~"}, {`"quoted"`}, {"snake_case name"}}
+	enc, err := Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(enc), "\"Hello_World\"\nHello_World\n") {
+		t.Errorf("Expected literal underscore to be quoted, got: %s", enc)
+	}
+
+	var dec []Data
+	if err := Unmarshal(enc, &dec); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(data, dec) {
+		t.Errorf("Roundtrip mismatch.\nOriginal: %q\nDecoded: %q", data, dec)
+	}
+
+	inline, err := Marshal(map[string]any{"path": "/var/my_app", "name": "my app"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]any
+	if err := Unmarshal(inline, &m); err != nil {
+		t.Fatal(err)
+	}
+	if m["path"] != "/var/my_app" || m["name"] != "my app" {
+		t.Errorf("Inline underscore roundtrip failed: %s -> %#v", inline, m)
 	}
 }
 
@@ -233,6 +282,89 @@ func TestFloatHandling(t *testing.T) {
 	if !strings.Contains(out, "0.75") {
 		t.Errorf("Float not serialized: %s", out)
 	}
+	if !strings.Contains(out, "value:f") {
+		t.Errorf("Expected f type code, got: %s", out)
+	}
+
+	var dec []Metric
+	if err := Unmarshal(enc, &dec); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(data, dec) {
+		t.Errorf("Roundtrip mismatch.\nOriginal: %+v\nDecoded: %+v", data, dec)
+	}
+}
+
+func TestFloat32Column(t *testing.T) {
+	type Point struct {
+		X float32 `zoon:"x"`
+		Y float64 `zoon:"y"`
+	}
+
+	data := []Point{{0.1, 1e-9}, {-2.5, 3.14159}}
+	enc, err := Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(enc), "# x:f y:f\n0.1 ") {
+		t.Errorf("Unexpected float32 encoding: %s", enc)
+	}
+
+	var dec []Point
+	if err := Unmarshal(enc, &dec); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(data, dec) {
+		t.Errorf("Roundtrip mismatch.\nOriginal: %+v\nDecoded: %+v", data, dec)
+	}
+
+	var precise []Point
+	if err := Unmarshal([]byte("# x:f2 y:f2\n1.50 2.25"), &precise); err != nil {
+		t.Fatal(err)
+	}
+	if precise[0].X != 1.5 || precise[0].Y != 2.25 {
+		t.Errorf("f2 column decode failed: %+v", precise)
+	}
+}
+
+func TestFloatDecode(t *testing.T) {
+	var m map[string]any
+	if err := Unmarshal([]byte("price:19.99 ratio:1e-3 name=1.5x"), &m); err != nil {
+		t.Fatal(err)
+	}
+	if m["price"] != 19.99 || m["ratio"] != 0.001 || m["name"] != "1.5x" {
+		t.Errorf("Inline floats decoded wrong: %#v", m)
+	}
+
+	type Item struct {
+		Qty   int     `zoon:"qty"`
+		Price float64 `zoon:"price"`
+	}
+
+	var items []Item
+	if err := Unmarshal([]byte("# qty:i price:i\n2 3\n4 5.5"), &items); err != nil {
+		t.Fatal(err)
+	}
+	if items[1].Price != 5.5 {
+		t.Errorf("Fractional value in i column lost: %+v", items)
+	}
+
+	err := Unmarshal([]byte("# qty:i price:i\n2 3\n4.5 5"), &items)
+	if !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("Expected ErrInvalidFormat for fractional int, got %v", err)
+	}
+
+	var one struct {
+		Name  string  `zoon:"name"`
+		Price float64 `zoon:"price"`
+	}
+	if err := Unmarshal([]byte("name=x price:abc"), &one); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("Expected ErrInvalidFormat for inline non-float, got %v", err)
+	}
+	err = Unmarshal([]byte("# qty:i price:s\n2 cheap\n"), &items)
+	if !errors.Is(err, ErrInvalidFormat) || !strings.Contains(err.Error(), "invalid float") {
+		t.Errorf("Expected ErrInvalidFormat for non-float s cell, got %v", err)
+	}
 }
 
 func TestSingleObject(t *testing.T) {
@@ -493,10 +625,3829 @@ func TestExplicitRowCount(t *testing.T) {
 	if err := Unmarshal(enc, &dec); err != nil {
 		t.Fatal(err)
 	}
-	if len(dec) != 3 {
-		t.Errorf("Expected 3 rows, got %d", len(dec))
+	if len(dec) != 3 || cap(dec) != 3 {
+		t.Errorf("Expected 3 rows in a slice sized by +3, got len %d cap %d", len(dec), cap(dec))
 	}
 	if dec[2].ID != 3 {
 		t.Errorf("ID generation failed, got %d", dec[2].ID)
 	}
 }
+
+func TestInlineMixedMap(t *testing.T) {
+	data := map[string]any{"a": int64(1), "b": "x y", "c": true, "d": "7"}
+
+	enc, err := Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "a:1 b=x_y c:y d=7"
+	if string(enc) != expected {
+		t.Errorf("Mixed map mismatch.\nGot: %q\nExp: %q", string(enc), expected)
+	}
+
+	var dec map[string]any
+	if err := Unmarshal(enc, &dec); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(data, dec) {
+		t.Errorf("Roundtrip mismatch.\nOriginal: %#v\nDecoded: %#v", data, dec)
+	}
+}
+
+func TestCompactFloats(t *testing.T) {
+	type Reading struct {
+		Sensor string  `zoon:"sensor"`
+		Value  float64 `zoon:"value"`
+	}
+
+	data := []Reading{
+		{"a", 2.0},
+		{"b", 3.0},
+	}
+
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf, WithCompactFloats(true)).Encode(data); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "a 2\n") || strings.Contains(out, "2.0") {
+		t.Errorf("Trailing zeros not stripped: %s", out)
+	}
+
+	var dec []Reading
+	if err := Unmarshal(buf.Bytes(), &dec); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(data, dec) {
+		t.Errorf("Roundtrip mismatch.\nOriginal: %+v\nDecoded: %+v", data, dec)
+	}
+
+	enc, err := Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(enc), "a 2.0\n") {
+		t.Errorf("Expected float formatting by default, got %s", enc)
+	}
+}
+
+func TestHexBytes(t *testing.T) {
+	type Blob struct {
+		Name string `zoon:"name"`
+		Data []byte `zoon:"data"`
+	}
+
+	data := []Blob{
+		{"a", []byte{0xde, 0xad, 0xbe, 0xef}},
+		{"b", []byte{}},
+		{"c", nil},
+	}
+
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf, WithByteEncoding(ByteEncodingHex)).Encode(data); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "data:h") || !strings.Contains(out, "deadbeef") {
+		t.Errorf("Expected hex column, got: %s", out)
+	}
+
+	var dec []Blob
+	if err := Unmarshal(buf.Bytes(), &dec); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(data, dec) {
+		t.Errorf("Roundtrip mismatch.\nOriginal: %#v\nDecoded: %#v", data, dec)
+	}
+}
+
+func TestHexBytesInline(t *testing.T) {
+	type B struct {
+		B    []byte            `zoon:"b"`
+		Tags map[string][]byte `zoon:"tags"`
+	}
+	in := B{[]byte("hi"), map[string][]byte{"k": []byte("yo")}}
+
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf, WithByteEncoding(ByteEncodingHex)).Encode(in); err != nil {
+		t.Fatal(err)
+	}
+	var got B
+	if err := Unmarshal(buf.Bytes(), &got); err != nil || !reflect.DeepEqual(got, in) {
+		t.Errorf("Inline bytes under hex encoding: %v %+v\n%s", err, got, buf.Bytes())
+	}
+
+	buf.Reset()
+	rows := []B{in, {[]byte{0xde, 0xad}, nil}}
+	if err := NewEncoder(&buf, WithByteEncoding(ByteEncodingHex), WithInlineMaps(true)).Encode(rows); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "b:h") || !strings.Contains(buf.String(), "dead") {
+		t.Errorf("Expected hex column, got: %s", buf.Bytes())
+	}
+	var gotRows []B
+	if err := Unmarshal(buf.Bytes(), &gotRows); err != nil || !reflect.DeepEqual(gotRows, rows) {
+		t.Errorf("Inline map bytes under hex encoding: %v %+v\n%s", err, gotRows, buf.Bytes())
+	}
+}
+
+func TestAliasWithoutSuffix(t *testing.T) {
+	type Row struct {
+		Server string `zoon:"server"`
+		Port   int    `zoon:"port"`
+	}
+
+	input := `%s=server %p=port
+# %s=alpha|beta @%p:8080
+alpha
+beta`
+	var rows []Row
+	if err := Unmarshal([]byte(input), &rows); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Row{{"alpha", 8080}, {"beta", 8080}}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Errorf("Bare alias decode failed.\nGot: %+v\nExp: %+v", rows, expected)
+	}
+}
+
+func TestWithoutAliases(t *testing.T) {
+	type Address struct {
+		Street string `zoon:"street"`
+		City   string `zoon:"city"`
+		Zip    string `zoon:"zip"`
+	}
+	type Customer struct {
+		Name    string  `zoon:"name"`
+		Address Address `zoon:"address"`
+	}
+
+	data := []Customer{
+		{"Ann", Address{"Main", "Oslo", "0150"}},
+		{"Ben", Address{"High", "Bergen", "5003"}},
+	}
+	withAliases, err := Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(withAliases), "%a=address\n") {
+		t.Fatalf("Expected an alias line by default, got:\n%s", withAliases)
+	}
+
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf, WithAliases(false)).Encode(data); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.HasPrefix(line, "%") || strings.Contains(line, " %") {
+			t.Errorf("Unexpected alias with WithAliases(false): %q", line)
+		}
+	}
+	if !strings.HasPrefix(buf.String(), "# address.city:s address.street:s address.zip:s name:s\n") {
+		t.Errorf("Expected full dotted paths, got:\n%s", buf.String())
+	}
+
+	var dec []Customer
+	if err := Unmarshal(buf.Bytes(), &dec); err != nil || !reflect.DeepEqual(dec, data) {
+		t.Errorf("Decode without aliases failed: %v\n got %+v\nwant %+v", err, dec, data)
+	}
+}
+
+func TestInlineMapCells(t *testing.T) {
+	type Service struct {
+		Name   string            `zoon:"name"`
+		Labels map[string]string `zoon:"labels"`
+	}
+
+	var data []Service
+	for i := 0; i < 6; i++ {
+		data = append(data, Service{
+			Name:   fmt.Sprintf("svc%d", i),
+			Labels: map[string]string{fmt.Sprintf("key%d", i): "some value", "env": "prod"},
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf, WithInlineMaps(true)).Encode(data); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	header := strings.SplitN(out, "\n", 2)[0]
+	if header != "# labels:s name:s" {
+		t.Errorf("Expected a single labels column, got header: %s", header)
+	}
+	if !strings.Contains(out, "{env=prod key0=some_value} svc0") {
+		t.Errorf("Expected inline object cell, got: %s", out)
+	}
+
+	var dec []Service
+	if err := Unmarshal(buf.Bytes(), &dec); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(data, dec) {
+		t.Errorf("Roundtrip mismatch.\nOriginal: %+v\nDecoded: %+v", data, dec)
+	}
+}
+
+type Severity int
+
+var severityNames = []string{"low", "medium", "high"}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(severityNames[s]), nil
+}
+
+func (s *Severity) UnmarshalText(b []byte) error {
+	i := slices.Index(severityNames, string(b))
+	if i < 0 {
+		return fmt.Errorf("unknown severity %q", b)
+	}
+	*s = Severity(i)
+	return nil
+}
+
+func TestAliasedIndexedIntEnum(t *testing.T) {
+	type Meta struct {
+		Severity Severity `zoon:"severity"`
+		Source   string   `zoon:"source"`
+	}
+	type Event struct {
+		Meta Meta `zoon:"metadata"`
+	}
+
+	input := `%m=metadata
+# %m.severity!low|medium|high %m.source:s
+2 api
+0 worker
+1 api`
+	var events []Event
+	if err := Unmarshal([]byte(input), &events); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Event{
+		{Meta{2, "api"}},
+		{Meta{0, "worker"}},
+		{Meta{1, "api"}},
+	}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("Indexed enum decode failed.\nGot: %+v\nExp: %+v", events, expected)
+	}
+
+	type Row struct {
+		Level Severity `zoon:"level"`
+		N     int      `zoon:"n"`
+	}
+	var rows []Row
+	for i := range 12 {
+		rows = append(rows, Row{Severity(i % 3), i * 7})
+	}
+	enc, err := Marshal(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(enc), "# level!high|low|medium") {
+		t.Errorf("Expected an indexed enum sorted by label: %s", enc)
+	}
+	var back []Row
+	if err := Unmarshal(enc, &back); err != nil || !reflect.DeepEqual(back, rows) {
+		t.Errorf("Text-marshaled int enum roundtrip: %v\n got %+v\nwant %+v", err, back, rows)
+	}
+
+	var plain []struct {
+		Level int `zoon:"level"`
+	}
+	if err := Unmarshal([]byte("# level!10|20\n1\n0\n"), &plain); err != nil || plain[0].Level != 20 || plain[1].Level != 10 {
+		t.Errorf("Numeric labels into an int: %v %+v", err, plain)
+	}
+}
+
+func TestObjectRows(t *testing.T) {
+	data := []map[string]any{
+		{"a": int64(1), "b": "x y"},
+		{"c": true},
+		{"d": map[string]any{"e": int64(2)}},
+	}
+
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf, WithObjectRows(true)).Encode(data); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "{a:1 b=x_y}\n{c:y}\n{d:{e:2}}\n"
+	if buf.String() != expected {
+		t.Errorf("Object rows mismatch.\nGot: %q\nExp: %q", buf.String(), expected)
+	}
+
+	var dec []map[string]any
+	if err := Unmarshal(buf.Bytes(), &dec); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(data, dec) {
+		t.Errorf("Roundtrip mismatch.\nOriginal: %#v\nDecoded: %#v", data, dec)
+	}
+}
+
+func TestSpaceEscapingDisabled(t *testing.T) {
+	type Doc struct {
+		Path  string `zoon:"path"`
+		Title string `zoon:"title"`
+	}
+
+	data := []Doc{
+		{"/var/log/app_server.log", "Daily report"},
+		{"snake_case_name", "Weekly summary"},
+	}
+
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf, WithSpaceEscaping(false)).Encode(data); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, `snake_case_name "Weekly summary"`) {
+		t.Errorf("Expected literal underscores and quoted spaces, got: %s", out)
+	}
+
+	var dec []Doc
+	if err := NewDecoder(&buf, WithSpaceEscaping(false)).Decode(&dec); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(data, dec) {
+		t.Errorf("Roundtrip mismatch.\nOriginal: %+v\nDecoded: %+v", data, dec)
+	}
+
+	cfg := map[string]any{"name": "my_app", "title": "My App"}
+	buf.Reset()
+	if err := NewEncoder(&buf, WithSpaceEscaping(false)).Encode(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != `name=my_app title="My App"` {
+		t.Errorf("Inline mismatch: %q", buf.String())
+	}
+
+	var decCfg map[string]any
+	if err := NewDecoder(&buf, WithSpaceEscaping(false)).Decode(&decCfg); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg, decCfg) {
+		t.Errorf("Inline roundtrip mismatch.\nOriginal: %#v\nDecoded: %#v", cfg, decCfg)
+	}
+}
+
+func TestInvalidUTF8(t *testing.T) {
+	type Person struct {
+		Name string `zoon:"name"`
+		City string `zoon:"city"`
+	}
+
+	// "José" and "Málaga" encoded as Latin-1.
+	input := []byte("# name:s city:s\nAna Madrid\nJos\xe9 M\xe1laga\n")
+
+	var strict []Person
+	err := NewDecoder(bytes.NewReader(input), WithStrict(true)).Decode(&strict)
+	if !errors.Is(err, ErrInvalidFormat) {
+		t.Fatalf("Expected ErrInvalidFormat in strict mode, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("Expected error to name line 3, got %v", err)
+	}
+
+	var lenient []Person
+	if err := Unmarshal(input, &lenient); err != nil {
+		t.Fatal(err)
+	}
+	if len(lenient) != 2 || lenient[1].Name != "Jos\xe9" {
+		t.Errorf("Expected raw bytes to pass through, got %+v", lenient)
+	}
+}
+
+func TestDeltaEncoding(t *testing.T) {
+	type Sample struct {
+		TS    int64 `zoon:"ts"`
+		Value int   `zoon:"value"`
+	}
+
+	data := []Sample{
+		{1700000000000, 5},
+		{1700000000250, 9},
+		{1700000000500, 2},
+		{1700000000400, 7},
+		{1700000001000, 1},
+	}
+
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf, WithDeltaEncoding(true)).Encode(data); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "ts:i^") {
+		t.Errorf("Expected delta column, got: %s", out)
+	}
+	if !strings.Contains(out, "\n-100 ") {
+		t.Errorf("Expected negative delta, got: %s", out)
+	}
+	if strings.Contains(out, "value:i^") {
+		t.Errorf("Delta applied to a column it does not shorten: %s", out)
+	}
+
+	var dec []Sample
+	if err := Unmarshal(buf.Bytes(), &dec); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(data, dec) {
+		t.Errorf("Roundtrip mismatch.\nOriginal: %+v\nDecoded: %+v", data, dec)
+	}
+}
+
+func TestBigNumbers(t *testing.T) {
+	type Account struct {
+		Owner   string     `zoon:"owner"`
+		Balance *big.Int   `zoon:"balance"`
+		Rate    *big.Float `zoon:"rate"`
+	}
+
+	huge, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	rate, _ := new(big.Float).SetString("0.125")
+	data := []Account{
+		{"alice", huge, rate},
+		{"bob", big.NewInt(-42), big.NewFloat(1.5)},
+	}
+
+	enc, err := Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(enc)
+	if !strings.Contains(out, "balance:i") || !strings.Contains(out, "123456789012345678901234567890") {
+		t.Errorf("Expected decimal big.Int column, got: %s", out)
+	}
+
+	var dec []Account
+	if err := Unmarshal(enc, &dec); err != nil {
+		t.Fatal(err)
+	}
+	for i := range data {
+		if dec[i].Balance.Cmp(data[i].Balance) != 0 {
+			t.Errorf("Balance %d mismatch: got %s, want %s", i, dec[i].Balance, data[i].Balance)
+		}
+		if dec[i].Rate.Cmp(data[i].Rate) != 0 {
+			t.Errorf("Rate %d mismatch: got %s, want %s", i, dec[i].Rate, data[i].Rate)
+		}
+	}
+}
+
+func TestSkipBadRows(t *testing.T) {
+	type Line struct {
+		Level string `zoon:"level"`
+		Count int    `zoon:"count"`
+	}
+
+	input := `# level:s count:i
+info 1
+warn oops
+error 3
+debug 4`
+
+	var strict []Line
+	if err := Unmarshal([]byte(input), &strict); !errors.Is(err, ErrInvalidFormat) {
+		t.Fatalf("Expected ErrInvalidFormat without skipping, got %v", err)
+	}
+
+	var lines []Line
+	err := NewDecoder(strings.NewReader(input), WithSkipBadRows(true)).Decode(&lines)
+	if !errors.Is(err, ErrInvalidFormat) || !strings.Contains(err.Error(), "row 2") {
+		t.Errorf("Expected an error for row 2, got %v", err)
+	}
+
+	expected := []Line{{"info", 1}, {"error", 3}, {"debug", 4}}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Good rows not kept.\nGot: %+v\nExp: %+v", lines, expected)
+	}
+}
+
+func TestEncodeChan(t *testing.T) {
+	users := []User{
+		{1, "Alice", "Admin", true},
+		{2, "Bob", "User", true},
+		{3, "Carol", "User", false},
+	}
+
+	ch := make(chan User)
+	go func() {
+		for _, u := range users {
+			ch <- u
+		}
+		close(ch)
+	}()
+
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).EncodeChan(ch); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `# active:b id:i name:s role:s
+1 1 Alice Admin
+1 2 Bob User
+0 3 Carol User
+`
+	if buf.String() != expected {
+		t.Errorf("Channel encoding mismatch.\nGot:\n%s\nExpected:\n%s", buf.String(), expected)
+	}
+
+	var dec []User
+	if err := Unmarshal(buf.Bytes(), &dec); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(users, dec) {
+		t.Errorf("Roundtrip mismatch.\nOriginal: %+v\nDecoded: %+v", users, dec)
+	}
+
+	empty := make(chan User)
+	close(empty)
+	buf.Reset()
+	if err := NewEncoder(&buf).EncodeChan(empty); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("Expected no output for an empty channel, got %q", buf.String())
+	}
+
+	if err := NewEncoder(&buf).EncodeChan(users); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("Expected ErrUnsupportedType for a non-channel, got %v", err)
+	}
+}
+
+func TestQuotedHeaderKeys(t *testing.T) {
+	data := []map[string]any{
+		{"full name": "Ada Lovelace", "born": int64(1815), "user_id": "ada"},
+		{"full name": "Alan Turing", "born": int64(1912), "user_id": "alan"},
+	}
+
+	enc, err := Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(enc)
+	if !strings.Contains(out, `"full name":s`) {
+		t.Errorf("Expected quoted column name, got: %s", out)
+	}
+
+	var dec []map[string]any
+	if err := Unmarshal(enc, &dec); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(data, dec) {
+		t.Errorf("Roundtrip mismatch.\nOriginal: %#v\nDecoded: %#v", data, dec)
+	}
+}
+
+func TestExplicitTypes(t *testing.T) {
+	type Job struct {
+		ID       int    `zoon:"id"`
+		Queue    string `zoon:"queue"`
+		Priority int    `zoon:"priority"`
+		Retry    bool   `zoon:"retry"`
+		Worker   string `zoon:"worker"`
+	}
+
+	data := []Job{
+		{1, "default", 5, true, "w1"},
+		{2, "default", 5, true, "w2"},
+	}
+
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf, WithExplicitTypes(true)).Encode(data); err != nil {
+		t.Fatal(err)
+	}
+	header := strings.SplitN(buf.String(), "\n", 2)[0]
+	expected := "# @priority:i=5 @queue=default @retry:b=1 id:i+ worker:s"
+	if header != expected {
+		t.Errorf("Header mismatch.\nGot: %s\nExp: %s", header, expected)
+	}
+
+	var dec []Job
+	if err := Unmarshal(buf.Bytes(), &dec); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(data, dec) {
+		t.Errorf("Roundtrip mismatch.\nOriginal: %+v\nDecoded: %+v", data, dec)
+	}
+}
+
+func TestNumericEnum(t *testing.T) {
+	type Review struct {
+		Rating int     `zoon:"rating"`
+		Score  float64 `zoon:"score"`
+		Label  string  `zoon:"label"`
+	}
+
+	var reviews []Review
+	data := "# rating=1|3|5 score!0.5|1.5 label=1|2\n3 1 2\n5 0 1"
+	if err := Unmarshal([]byte(data), &reviews); err != nil {
+		t.Fatal(err)
+	}
+	want := []Review{{3, 1.5, "2"}, {5, 0.5, "1"}}
+	if !reflect.DeepEqual(reviews, want) {
+		t.Errorf("Numeric enum decode failed.\nGot: %+v\nWant: %+v", reviews, want)
+	}
+
+	err := Unmarshal([]byte("# rating=1|2.5\n2.5"), &reviews)
+	if !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("Expected ErrInvalidFormat for fractional enum, got %v", err)
+	}
+}
+
+func TestPrimitiveList(t *testing.T) {
+	strs := []string{"a", "b c", "d"}
+	enc, err := Marshal(strs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(enc) != "# :s\na\nb_c\nd\n" {
+		t.Errorf("Unexpected string list encoding: %q", enc)
+	}
+	var decStrs []string
+	if err := Unmarshal(enc, &decStrs); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(strs, decStrs) {
+		t.Errorf("String list mismatch: %v", decStrs)
+	}
+
+	ints := []int{1, 2, 3}
+	enc, err = Marshal(ints)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(enc) != "# :i\n1\n2\n3\n" {
+		t.Errorf("Unexpected int list encoding: %q", enc)
+	}
+	var decInts []int
+	if err := Unmarshal(enc, &decInts); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ints, decInts) {
+		t.Errorf("Int list mismatch: %v", decInts)
+	}
+
+	// Repeated values still become an enum rather than a constant.
+	roles := []string{"admin", "user", "user", "admin"}
+	enc, err = Marshal(roles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decRoles []string
+	if err := Unmarshal(enc, &decRoles); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(roles, decRoles) {
+		t.Errorf("Enum list mismatch: %q decoded to %v", enc, decRoles)
+	}
+
+	same := []*float64{new(float64), new(float64), nil}
+	enc, err = Marshal(same)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decSame []*float64
+	if err := Unmarshal(enc, &decSame); err != nil {
+		t.Fatal(err)
+	}
+	if len(decSame) != 3 || decSame[0] == nil || *decSame[0] != 0 || decSame[2] != nil {
+		t.Errorf("Pointer list mismatch: %q decoded to %v", enc, decSame)
+	}
+}
+
+func TestUnsignedInts(t *testing.T) {
+	type Counter struct {
+		A uint   `zoon:"a"`
+		B uint8  `zoon:"b"`
+		C uint16 `zoon:"c"`
+		D uint32 `zoon:"d"`
+		E uint64 `zoon:"e"`
+	}
+
+	data := []Counter{{1, 2, 3, 4, 5}, {10, 255, 65535, 4294967295, 1 << 63}}
+	enc, err := Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(enc), "# a:u b:u c:u d:u e:u\n") {
+		t.Errorf("Expected u type codes, got: %s", enc)
+	}
+
+	var dec []Counter
+	if err := Unmarshal(enc, &dec); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(data, dec) {
+		t.Errorf("Roundtrip mismatch.\nOriginal: %+v\nDecoded: %+v", data, dec)
+	}
+
+	var cfg struct {
+		Port uint16 `zoon:"port"`
+	}
+	if err := Unmarshal([]byte("port:-1"), &cfg); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("Expected ErrInvalidFormat for negative unsigned, got %v", err)
+	}
+	if err := Unmarshal([]byte("port:8080"), &cfg); err != nil || cfg.Port != 8080 {
+		t.Errorf("Inline unsigned decode failed: %v %+v", err, cfg)
+	}
+}
+
+func TestMaxUint64(t *testing.T) {
+	type Row struct {
+		ID  uint   `zoon:"id"`
+		Max uint64 `zoon:"max"`
+	}
+
+	data := []Row{{1, math.MaxUint64}, {2, 0}, {3, math.MaxUint64 - 1}}
+	enc, err := Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(enc), "# id:i+ max:u\n") {
+		t.Errorf("Unexpected header: %s", enc)
+	}
+
+	var dec []Row
+	if err := Unmarshal(enc, &dec); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(data, dec) {
+		t.Errorf("Roundtrip mismatch.\nOriginal: %+v\nDecoded: %+v", data, dec)
+	}
+
+	// Older documents write unsigned columns as i.
+	var legacy []Row
+	if err := Unmarshal([]byte("# id:i max:i\n7 18446744073709551615"), &legacy); err != nil {
+		t.Fatal(err)
+	}
+	if legacy[0].ID != 7 || legacy[0].Max != math.MaxUint64 {
+		t.Errorf("Max uint64 in i column decoded wrong: %+v", legacy)
+	}
+
+	var m map[string]any
+	if err := Unmarshal([]byte("max:18446744073709551615"), &m); err != nil {
+		t.Fatal(err)
+	}
+	if m["max"] != uint64(math.MaxUint64) {
+		t.Errorf("Inline max uint64 decoded wrong: %#v", m["max"])
+	}
+}
+
+type Money struct {
+	Currency string
+	Cents    int64
+}
+
+func (m Money) MarshalZoon() ([]byte, error) {
+	if m.Currency == "" {
+		return nil, errors.New("missing currency")
+	}
+	return []byte(fmt.Sprintf("%s:%d", m.Currency, m.Cents)), nil
+}
+
+func (m *Money) UnmarshalZoon(b []byte) error {
+	cur, cents, ok := strings.Cut(string(b), ":")
+	if !ok {
+		return fmt.Errorf("bad money %q", b)
+	}
+	n, err := strconv.ParseInt(cents, 10, 64)
+	if err != nil {
+		return err
+	}
+	m.Currency, m.Cents = cur, n
+	return nil
+}
+
+func TestMarshaler(t *testing.T) {
+	type Product struct {
+		Name  string `zoon:"name"`
+		Price Money  `zoon:"price"`
+		Sale  *Money `zoon:"sale"`
+	}
+
+	data := []Product{
+		{"Book", Money{"USD", 1099}, nil},
+		{"Pen", Money{"EUR", 250}, &Money{"EUR", 199}},
+	}
+	enc, err := Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(enc), "Book USD:1099 ~") {
+		t.Errorf("Expected custom money token, got: %s", enc)
+	}
+
+	var dec []Product
+	if err := Unmarshal(enc, &dec); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(data, dec) {
+		t.Errorf("Roundtrip mismatch.\nOriginal: %+v\nDecoded: %+v", data, dec)
+	}
+
+	inline, err := Marshal(Product{Name: "Cup", Price: Money{"USD", 500}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(inline) != "name=Cup price=USD:500 sale:~" {
+		t.Errorf("Unexpected inline encoding: %s", inline)
+	}
+	var p Product
+	if err := Unmarshal(inline, &p); err != nil || p.Price != (Money{"USD", 500}) {
+		t.Errorf("Inline decode failed: %v %+v", err, p)
+	}
+
+	if _, err := Marshal([]Product{{Name: "Bad"}}); err == nil || !strings.Contains(err.Error(), "missing currency") {
+		t.Errorf("Expected MarshalZoon error, got %v", err)
+	}
+	if err := Unmarshal([]byte("name=X price=oops"), &p); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("Expected ErrInvalidFormat from UnmarshalZoon, got %v", err)
+	}
+}
+
+func TestIntOverflow(t *testing.T) {
+	type Wide struct {
+		N int64 `zoon:"n"`
+	}
+	var wide []Wide
+	if err := Unmarshal([]byte("# n:i\n9223372036854775807\n-9223372036854775808"), &wide); err != nil {
+		t.Fatal(err)
+	}
+	if wide[0].N != math.MaxInt64 || wide[1].N != math.MinInt64 {
+		t.Errorf("int64 extremes decoded wrong: %+v", wide)
+	}
+
+	type Narrow struct {
+		N int8 `zoon:"n"`
+	}
+	var narrow []Narrow
+	if err := Unmarshal([]byte("# n:i\n127\n128"), &narrow); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("Expected ErrInvalidFormat for int8 overflow, got %v", err)
+	}
+
+	var small struct {
+		N uint8 `zoon:"n"`
+	}
+	if err := Unmarshal([]byte("n:256"), &small); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("Expected ErrInvalidFormat for uint8 overflow, got %v", err)
+	}
+	if err := Unmarshal([]byte("n:99999999999999999999999"), &wide[0]); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("Expected ErrInvalidFormat for big overflow, got %v", err)
+	}
+}
+
+func TestStrictEnums(t *testing.T) {
+	type User struct {
+		Name string `zoon:"name"`
+		Role string `zoon:"role"`
+	}
+
+	valid := "# name:s role=Admin|User\nAlice Admin\nBob User"
+	var users []User
+	if err := NewDecoder(strings.NewReader(valid), WithStrict(true)).Decode(&users); err != nil {
+		t.Fatalf("Valid enum rejected: %v", err)
+	}
+	if users[0].Role != "Admin" || users[1].Role != "User" {
+		t.Errorf("Unexpected roles: %+v", users)
+	}
+
+	bad := "# name:s role=Admin|User\nAlice Superadmin"
+	err := NewDecoder(strings.NewReader(bad), WithStrict(true)).Decode(&users)
+	if !errors.Is(err, ErrInvalidFormat) || !strings.Contains(err.Error(), "Admin|User") {
+		t.Errorf("Expected invalid enum error, got %v", err)
+	}
+	if err := Unmarshal([]byte(bad), &users); err != nil || users[0].Role != "Superadmin" {
+		t.Errorf("Lenient mode should pass the value through: %v %+v", err, users)
+	}
+
+	badIndex := "# name:s role!Admin|User\nAlice 2"
+	err = NewDecoder(strings.NewReader(badIndex), WithStrict(true)).Decode(&users)
+	if !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("Expected out-of-range index error, got %v", err)
+	}
+
+	// Bad indexes fail in lenient mode too, naming the column and value.
+	for _, tc := range []struct{ data, msg string }{
+		{"# name:s role!Admin|User\nAlice 0\nBob 2", `enum index "2" out of range for column role`},
+		{"# name:s role!Admin|User\nAlice -1", `enum index "-1" out of range for column role`},
+		{"# name:s role!Admin|User\nAlice Admin", `enum index "Admin" is not a number for column role`},
+	} {
+		err := Unmarshal([]byte(tc.data), &users)
+		if !errors.Is(err, ErrInvalidFormat) || !strings.Contains(err.Error(), tc.msg) {
+			t.Errorf("Expected %q, got %v", tc.msg, err)
+		}
+	}
+	if err := Unmarshal([]byte("# name:s role!Admin|User\nAlice ~"), &users); err != nil || users[0].Role != "" {
+		t.Errorf("Null index: %v %+v", err, users)
+	}
+}
+
+func TestOmitEmpty(t *testing.T) {
+	type Config struct {
+		Host  string            `zoon:"host,omitempty"`
+		Port  int               `zoon:"port,omitempty"`
+		SSL   bool              `zoon:"ssl,omitempty"`
+		Proxy *string           `zoon:"proxy,omitempty"`
+		Tags  map[string]string `zoon:"tags,omitempty"`
+		Name  string            `zoon:"name"`
+	}
+
+	enc, err := Marshal(Config{Port: 8080})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(enc) != `port:8080 name=""` {
+		t.Errorf("Unexpected inline encoding: %q", enc)
+	}
+
+	rows := []Config{{Name: "a", Port: 1}, {Name: "b"}, {Name: "c", Port: 3}}
+	enc, err = Marshal(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(enc), "# name:s port:i\n") {
+		t.Errorf("Expected empty columns to be omitted, got: %s", enc)
+	}
+	var dec []Config
+	if err := Unmarshal(enc, &dec); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rows, dec) {
+		t.Errorf("Roundtrip mismatch.\nOriginal: %+v\nDecoded: %+v", rows, dec)
+	}
+}
+
+func TestOmitEmptyOptionalFields(t *testing.T) {
+	type Contact struct {
+		Name  string  `json:"name"`
+		Email *string `json:"email,omitempty"`
+		Count int     `json:"count,omitempty"`
+	}
+
+	email := "a@example.com"
+	enc, err := Marshal(Contact{Name: "Ann", Email: &email})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(enc) != "name=Ann email=a@example.com" {
+		t.Errorf("Unexpected inline encoding: %s", enc)
+	}
+
+	enc, err = Marshal([]Contact{{Name: "Ann"}, {Name: "Bob", Email: &email}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(enc), "# email:s name:s\n~ Ann\n") {
+		t.Errorf("Expected count to be omitted and email kept, got: %s", enc)
+	}
+}
+
+func TestSchema(t *testing.T) {
+	type Event struct {
+		ID     int    `zoon:"id"`
+		Level  string `zoon:"level"`
+		Active bool   `zoon:"active"`
+	}
+
+	sample := []Event{{1, "info", true}, {2, "warn", false}, {3, "info", true}, {4, "error", false}}
+	schema, err := DeriveSchema(sample)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := schema.String(); got != "# active:b id:i level=error|info|warn" {
+		t.Errorf("Unexpected schema header: %s", got)
+	}
+
+	batches := [][]Event{
+		{{10, "warn", true}, {11, "warn", true}},
+		{{1, "error", false}, {2, "info", true}, {3, "info", false}},
+	}
+	for _, batch := range batches {
+		enc, err := MarshalWith(batch, schema)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(string(enc), schema.String()+"\n") {
+			t.Errorf("Batch header differs from schema: %s", enc)
+		}
+		var dec []Event
+		if err := Unmarshal(enc, &dec); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(batch, dec) {
+			t.Errorf("Roundtrip mismatch.\nOriginal: %+v\nDecoded: %+v", batch, dec)
+		}
+	}
+
+	_, err = MarshalWith([]Event{{1, "debug", true}}, schema)
+	if !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("Expected error for value outside schema enum, got %v", err)
+	}
+}
+
+func TestRowScanner(t *testing.T) {
+	type Event struct {
+		ID    int    `zoon:"id"`
+		Level string `zoon:"level"`
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		fmt.Fprint(pw, "# id:i+ level=info|warn\ninfo\n")
+		// The first row must be readable before the rest is written.
+		fmt.Fprint(pw, "warn\n")
+		pw.Close()
+	}()
+
+	sc := NewDecoder(pr).Scanner(Event{})
+	var got []Event
+	for sc.Scan() {
+		got = append(got, sc.Row().(Event))
+	}
+	if err := sc.Err(); err != nil {
+		t.Fatal(err)
+	}
+	want := []Event{{1, "info"}, {2, "warn"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Scanned rows mismatch.\nGot: %+v\nWant: %+v", got, want)
+	}
+
+	ptrs := NewDecoder(strings.NewReader("# id:i+ +3")).Scanner(&Event{})
+	n := 0
+	for ptrs.Scan() {
+		n++
+		if ev := ptrs.Row().(*Event); ev.ID != n {
+			t.Errorf("Row %d has ID %d", n, ev.ID)
+		}
+	}
+	if ptrs.Err() != nil || n != 3 {
+		t.Errorf("Expected 3 implied rows, got %d (%v)", n, ptrs.Err())
+	}
+
+	bad := NewDecoder(strings.NewReader("# n:i\n1\nx\n3"), WithSkipBadRows(true)).Scanner(struct {
+		N int `zoon:"n"`
+	}{})
+	n = 0
+	for bad.Scan() {
+		n++
+	}
+	if n != 2 || !errors.Is(bad.Err(), ErrInvalidFormat) {
+		t.Errorf("Expected 2 rows and a row error, got %d rows (%v)", n, bad.Err())
+	}
+}
+
+func TestInlineQuotedValues(t *testing.T) {
+	var m map[string]any
+	if err := Unmarshal([]byte(`name:"a_b c" k:"}" nested:{v="x}y" n:1} tail=ok`), &m); err != nil {
+		t.Fatal(err)
+	}
+	if m["name"] != "a_b c" || m["k"] != "}" || m["tail"] != "ok" {
+		t.Errorf("Quoted inline values decoded wrong: %#v", m)
+	}
+	nested, _ := m["nested"].(map[string]any)
+	if nested["v"] != "x}y" || nested["n"] != int64(1) {
+		t.Errorf("Quoted brace inside object decoded wrong: %#v", m["nested"])
+	}
+
+	type Doc struct {
+		Title string            `zoon:"title"`
+		Meta  map[string]string `zoon:"meta"`
+	}
+	doc := Doc{Title: "a_b c", Meta: map[string]string{"close": "}", "open": "{", "quote": `say "hi"`, "empty": ""}}
+	enc, err := Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dec Doc
+	if err := Unmarshal(enc, &dec); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(doc, dec) {
+		t.Errorf("Roundtrip mismatch for %s.\nOriginal: %#v\nDecoded: %#v", enc, doc, dec)
+	}
+}
+
+func TestBoolStyle(t *testing.T) {
+	type Flag struct {
+		Name    string `zoon:"name"`
+		Enabled bool   `zoon:"enabled"`
+		Beta    bool   `zoon:"beta"`
+	}
+
+	rows := []Flag{{"a", true, false}, {"b", true, true}}
+	cases := []struct {
+		style  BoolStyle
+		header string
+		inline string
+	}{
+		{BoolStyleDefault, "# @enabled:y beta:b name:s", "name=a enabled:y beta:n"},
+		{BoolStyleYesNo, "# @enabled:y beta:b name:s", "name=a enabled:y beta:n"},
+		{BoolStyleTrueFalse, "# @enabled:true beta:b name:s", "name=a enabled:true beta:false"},
+		{BoolStyleOneZero, "# @enabled:b=1 beta:b name:s", "name=a enabled:1 beta:0"},
+	}
+	for _, c := range cases {
+		var buf bytes.Buffer
+		if err := NewEncoder(&buf, WithBoolStyle(c.style)).Encode(rows); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(buf.String(), c.header+"\n") {
+			t.Errorf("Style %d: unexpected table: %s", c.style, buf.String())
+		}
+		var dec []Flag
+		if err := Unmarshal(buf.Bytes(), &dec); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(rows, dec) {
+			t.Errorf("Style %d: roundtrip mismatch: %+v", c.style, dec)
+		}
+
+		buf.Reset()
+		if err := NewEncoder(&buf, WithBoolStyle(c.style)).Encode(rows[0]); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != c.inline {
+			t.Errorf("Style %d: unexpected inline: %s", c.style, buf.String())
+		}
+		var one Flag
+		if err := Unmarshal(buf.Bytes(), &one); err != nil || one != rows[0] {
+			t.Errorf("Style %d: inline roundtrip failed: %v %+v", c.style, err, one)
+		}
+	}
+}
+
+type Stamp struct{ time.Time }
+
+func (s Stamp) MarshalZoon() ([]byte, error) {
+	return []byte(s.UTC().Format(time.RFC3339)), nil
+}
+
+func (s *Stamp) UnmarshalZoon(b []byte) error {
+	t, err := time.Parse(time.RFC3339, string(b))
+	s.Time = t
+	return err
+}
+
+func TestMarshalerTimestamp(t *testing.T) {
+	type Entry struct {
+		At  Stamp  `zoon:"at"`
+		Msg string `zoon:"msg"`
+	}
+
+	at := Stamp{time.Date(2025, 3, 1, 12, 30, 0, 0, time.UTC)}
+	enc, err := Marshal(Entry{At: at, Msg: "boot"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(enc) != "at=2025-03-01T12:30:00Z msg=boot" {
+		t.Errorf("Unexpected encoding: %s", enc)
+	}
+
+	var dec Entry
+	if err := Unmarshal(enc, &dec); err != nil {
+		t.Fatal(err)
+	}
+	if !dec.At.Equal(at.Time) || dec.Msg != "boot" {
+		t.Errorf("Timestamp roundtrip failed: %+v", dec)
+	}
+}
+
+func TestContextCancel(t *testing.T) {
+	type Row struct {
+		N int `zoon:"n"`
+	}
+	rows := []Row{{5}, {7}, {9}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var buf bytes.Buffer
+	err := NewEncoder(&buf).EncodeContext(ctx, rows)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled from encode, got %v", err)
+	}
+	if buf.String() != "# n:i\n5\n" {
+		t.Errorf("Expected encoding to stop after the first row, got %q", buf.String())
+	}
+
+	var dec []Row
+	err = NewDecoder(strings.NewReader("# n:i\n5\n7")).DecodeContext(ctx, &dec)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled from decode, got %v", err)
+	}
+
+	if err := NewDecoder(strings.NewReader("# n:i\n5\n7")).DecodeContext(context.Background(), &dec); err != nil || len(dec) != 2 {
+		t.Errorf("Decode with live context failed: %v %+v", err, dec)
+	}
+}
+
+func TestUnsupportedFields(t *testing.T) {
+	type Job struct {
+		Name string    `zoon:"name"`
+		Run  func()    `zoon:"run"`
+		Done chan bool `zoon:"done"`
+		Tags []string  `zoon:"tags"`
+	}
+
+	job := Job{Name: "build", Run: func() {}, Done: make(chan bool)}
+	enc, err := Marshal(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(enc) != "name=build tags:~" {
+		t.Errorf("Expected func and chan fields to be skipped, got: %s", enc)
+	}
+
+	enc, err = Marshal([]Job{job, job})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(enc), "run") || strings.Contains(string(enc), "done") {
+		t.Errorf("Expected func and chan columns to be skipped, got: %s", enc)
+	}
+
+	enc, err = Marshal(map[string]any{"cb": func() {}, "n": 1})
+	if err != nil || string(enc) != "n:1" {
+		t.Errorf("Expected func map value to be skipped, got: %s (%v)", enc, err)
+	}
+
+	var buf bytes.Buffer
+	err = NewEncoder(&buf, WithUnsupportedError(true)).Encode(job)
+	if !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("Expected ErrUnsupportedType, got %v", err)
+	}
+}
+
+type Level string
+
+func (l *Level) UnmarshalZoon(b []byte) error {
+	switch s := string(b); s {
+	case "low", "high":
+		*l = Level(s)
+		return nil
+	default:
+		return fmt.Errorf("unknown level %q", s)
+	}
+}
+
+func TestUnmarshaler(t *testing.T) {
+	type Alert struct {
+		Level  Level  `zoon:"level"`
+		Backup *Level `zoon:"backup"`
+	}
+
+	var a Alert
+	if err := Unmarshal([]byte("level=high backup=low"), &a); err != nil {
+		t.Fatal(err)
+	}
+	if a.Level != "high" || a.Backup == nil || *a.Backup != "low" {
+		t.Errorf("Unmarshaler fields decoded wrong: %+v", a)
+	}
+
+	err := Unmarshal([]byte("level=urgent"), &a)
+	if !errors.Is(err, ErrInvalidFormat) || !strings.Contains(err.Error(), "level") || !strings.Contains(err.Error(), "unknown level") {
+		t.Errorf("Expected wrapped UnmarshalZoon error, got %v", err)
+	}
+
+	var prices map[string]Money
+	if err := Unmarshal([]byte("book=USD:1099 pen=EUR:250"), &prices); err != nil {
+		t.Fatal(err)
+	}
+	if prices["book"] != (Money{"USD", 1099}) || prices["pen"] != (Money{"EUR", 250}) {
+		t.Errorf("Unmarshaler map values decoded wrong: %+v", prices)
+	}
+
+	var sizes map[string]int64
+	if err := Unmarshal([]byte("a:1 b:2"), &sizes); err != nil || sizes["b"] != 2 {
+		t.Errorf("Typed map values decoded wrong: %v %+v", err, sizes)
+	}
+}
+
+func TestCommentLines(t *testing.T) {
+	type User struct {
+		Name string `zoon:"name"`
+		Team string `zoon:"team"`
+	}
+
+	data := `// generated by tool v1.2
+// edit with care
+%t=team
+# name:s %t:s
+// first batch
+Alice core
+  // indented remark
+Bob //web
+`
+	var users []User
+	if err := Unmarshal([]byte(data), &users); err != nil {
+		t.Fatal(err)
+	}
+	want := []User{{"Alice", "core"}, {"Bob", "//web"}}
+	if !reflect.DeepEqual(users, want) {
+		t.Errorf("Comment lines not skipped.\nGot: %+v\nWant: %+v", users, want)
+	}
+
+	var cfg map[string]any
+	if err := Unmarshal([]byte("// config\nhost=localhost port:8080"), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg["host"] != "localhost" || cfg["port"] != int64(8080) {
+		t.Errorf("Inline after comment decoded wrong: %#v", cfg)
+	}
+
+	paths := []User{{"//root", "x"}, {"b", "y"}}
+	enc, err := Marshal(paths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dec []User
+	if err := Unmarshal(enc, &dec); err != nil || !reflect.DeepEqual(paths, dec) {
+		t.Errorf("Leading // value roundtrip failed for %s: %v %+v", enc, err, dec)
+	}
+}
+
+func TestRawField(t *testing.T) {
+	type Row struct {
+		ID   int    `zoon:"id"`
+		Name string `zoon:"name"`
+		Raw  string `zoon:",raw"`
+	}
+
+	var rows []Row
+	if err := Unmarshal([]byte("# id:i name:s\n1 Alice\n  2 Bob_Smith  "), &rows); err != nil {
+		t.Fatal(err)
+	}
+	if rows[0].Raw != "1 Alice" || rows[1].Raw != "2 Bob_Smith" {
+		t.Errorf("Raw rows not captured: %+v", rows)
+	}
+
+	enc, err := Marshal(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(enc) != "# id:i+ name:s\nAlice\nBob_Smith\n" {
+		t.Errorf("Raw field should not be encoded: %s", enc)
+	}
+
+	var doc struct {
+		Host string `zoon:"host"`
+		Src  []byte `zoon:"src,raw"`
+	}
+	if err := Unmarshal([]byte("host=localhost port:80"), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if string(doc.Src) != "host=localhost port:80" {
+		t.Errorf("Raw document not captured: %q", doc.Src)
+	}
+}
+
+func TestBoolIntField(t *testing.T) {
+	type Flag struct {
+		Name    string `zoon:"name"`
+		Enabled int    `zoon:"enabled,bool"`
+	}
+
+	flags := []Flag{{"a", 1}, {"b", 0}}
+	enc, err := Marshal(flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(enc), "enabled:b") {
+		t.Errorf("Expected b column for int tagged bool: %s", enc)
+	}
+	var dec []Flag
+	if err := Unmarshal(enc, &dec); err != nil || !reflect.DeepEqual(flags, dec) {
+		t.Errorf("Bool int roundtrip failed for %s: %v %+v", enc, err, dec)
+	}
+
+	inline, err := Marshal(Flag{"a", 1})
+	if err != nil || string(inline) != "name=a enabled:y" {
+		t.Errorf("Inline bool int: %s %v", inline, err)
+	}
+	if _, err := Marshal([]Flag{{"a", 2}}); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("Expected ErrUnsupportedType for 2 tagged bool, got %v", err)
+	}
+
+	var plain []struct {
+		Active uint8 `zoon:"active"`
+	}
+	if err := Unmarshal([]byte("# active:b\n1\n0"), &plain); err != nil {
+		t.Fatal(err)
+	}
+	if plain[0].Active != 1 || plain[1].Active != 0 {
+		t.Errorf("Bool cells not converted to int: %+v", plain)
+	}
+}
+
+func TestTimeValues(t *testing.T) {
+	type Event struct {
+		Name    string    `zoon:"name"`
+		Created time.Time `zoon:"created"`
+	}
+
+	base := time.Date(2025, 3, 1, 12, 30, 0, 0, time.UTC)
+	events := []Event{{"a", base}, {"b", base.Add(time.Hour)}, {"c", base.Add(2 * time.Hour)}}
+	enc, err := Marshal(events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(enc), "created:time") || !strings.Contains(string(enc), "2025-03-01T13:30:00Z") {
+		t.Errorf("Expected RFC 3339 time column: %s", enc)
+	}
+	var dec []Event
+	if err := Unmarshal(enc, &dec); err != nil || !reflect.DeepEqual(events, dec) {
+		t.Errorf("Time roundtrip failed for %s: %v %+v", enc, err, dec)
+	}
+
+	var anyRows []map[string]any
+	if err := Unmarshal(enc, &anyRows); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := anyRows[0]["created"].(time.Time); !ok || !got.Equal(base) {
+		t.Errorf("Expected time.Time in map, got %#v", anyRows[0]["created"])
+	}
+
+	inline, err := Marshal(events[0])
+	if err != nil || string(inline) != "name=a created=2025-03-01T12:30:00Z" {
+		t.Errorf("Inline time: %s %v", inline, err)
+	}
+	var one Event
+	if err := Unmarshal(inline, &one); err != nil || !one.Created.Equal(base) {
+		t.Errorf("Inline time decode: %v %+v", err, one)
+	}
+
+	layout := "2006-01-02 15:04"
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf, WithTimeLayout(layout)).Encode(events[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "2025-03-01_12:30") {
+		t.Errorf("Custom layout not used: %s", buf.String())
+	}
+	dec = nil
+	if err := NewDecoder(&buf, WithTimeLayout(layout)).Decode(&dec); err != nil || !dec[0].Created.Equal(base) {
+		t.Errorf("Custom layout decode: %v %+v", err, dec)
+	}
+
+	if err := Unmarshal([]byte("created=yesterday"), &one); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("Expected ErrInvalidFormat for bad time, got %v", err)
+	}
+}
+
+func TestStrictFields(t *testing.T) {
+	type User struct {
+		ID   int    `zoon:"id"`
+		Name string `zoon:"name"`
+	}
+
+	data := "# id:i+ nmae:s\nAlice\nBob"
+	var users []User
+	if err := Unmarshal([]byte(data), &users); err != nil || len(users) != 2 {
+		t.Errorf("Unknown columns should be ignored by default: %v %+v", err, users)
+	}
+
+	users = nil
+	err := NewDecoder(strings.NewReader(data), WithStrictFields(true)).Decode(&users)
+	if !errors.Is(err, ErrInvalidFormat) || !strings.Contains(err.Error(), `"nmae"`) {
+		t.Errorf("Expected unknown field error, got %v", err)
+	}
+
+	var u User
+	err = NewDecoder(strings.NewReader("id:1 nmae=Alice"), WithStrictFields(true)).Decode(&u)
+	if !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("Expected unknown inline field error, got %v", err)
+	}
+
+	var m []map[string]any
+	if err := NewDecoder(strings.NewReader(data), WithStrictFields(true)).Decode(&m); err != nil {
+		t.Errorf("Maps accept any key: %v", err)
+	}
+}
+
+func TestDecodeInto(t *testing.T) {
+	type User struct {
+		ID   int    `zoon:"id"`
+		Name string `zoon:"name"`
+	}
+	type Envelope struct {
+		Kind  string
+		Users []User
+	}
+
+	var env Envelope
+	field := reflect.ValueOf(&env).Elem().FieldByName("Users")
+	if err := DecodeInto([]byte("# id:i+ name:s\nAlice\nBob"), field); err != nil {
+		t.Fatal(err)
+	}
+	want := []User{{1, "Alice"}, {2, "Bob"}}
+	if !reflect.DeepEqual(env.Users, want) {
+		t.Errorf("DecodeInto field: %+v", env.Users)
+	}
+
+	rv := reflect.New(reflect.TypeOf(User{})).Elem()
+	if err := DecodeInto([]byte("id:7 name=Carol"), rv); err != nil || rv.Interface().(User) != (User{7, "Carol"}) {
+		t.Errorf("DecodeInto constructed value: %v %+v", err, rv.Interface())
+	}
+
+	if err := DecodeInto([]byte("id:1"), reflect.ValueOf(User{})); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("Expected ErrUnsupportedType for unsettable value, got %v", err)
+	}
+	if err := DecodeInto([]byte("id:1"), reflect.Value{}); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("Expected ErrUnsupportedType for invalid value, got %v", err)
+	}
+}
+
+func TestTimeRoundTrip(t *testing.T) {
+	type Post struct {
+		ID        int        `zoon:"id"`
+		CreatedAt time.Time  `zoon:"created_at"`
+		DeletedAt *time.Time `zoon:"deleted_at"`
+	}
+
+	zone := time.FixedZone("", -5*60*60)
+	created := time.Date(2024, 12, 31, 23, 59, 59, 0, zone)
+	deleted := created.Add(24 * time.Hour)
+	posts := []Post{
+		{1, created, nil},
+		{2, created.Add(time.Minute), &deleted},
+		{3, created.Add(time.Hour), nil},
+	}
+	enc, err := Marshal(posts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(enc), "2024-12-31T23:59:59-05:00") {
+		t.Errorf("Expected zone offset kept: %s", enc)
+	}
+
+	var dec []Post
+	if err := Unmarshal(enc, &dec); err != nil {
+		t.Fatal(err)
+	}
+	for i := range posts {
+		if !dec[i].CreatedAt.Equal(posts[i].CreatedAt) || (dec[i].DeletedAt == nil) != (posts[i].DeletedAt == nil) {
+			t.Errorf("Row %d: got %+v want %+v", i, dec[i], posts[i])
+		}
+	}
+	if !dec[1].DeletedAt.Equal(deleted) {
+		t.Errorf("DeletedAt: got %v want %v", dec[1].DeletedAt, deleted)
+	}
+}
+
+func TestTranscodeJSON(t *testing.T) {
+	in := `[{"id":1,"name":"Alice","score":9.5,"active":true,"team":null},
+		{"id":2,"name":"Bob","score":7,"active":false,"team":"red"}]`
+
+	var zb bytes.Buffer
+	if err := TranscodeFromJSON(strings.NewReader(in), &zb); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"active:b", "score:f", "id:i+", "~"} {
+		if !strings.Contains(zb.String(), want) {
+			t.Errorf("Expected %q in %s", want, zb.String())
+		}
+	}
+
+	var jb bytes.Buffer
+	if err := TranscodeToJSON(bytes.NewReader(zb.Bytes()), &jb); err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"active":true,"id":1,"name":"Alice","score":9.5,"team":null},{"active":false,"id":2,"name":"Bob","score":7,"team":"red"}]` + "\n"
+	if jb.String() != want {
+		t.Errorf("JSON round trip:\n got %s\nwant %s", jb.String(), want)
+	}
+
+	zb.Reset()
+	if err := TranscodeFromJSON(strings.NewReader(`{"host":"localhost","port":8080,"ratio":1.0}`), &zb); err != nil {
+		t.Fatal(err)
+	}
+	if zb.String() != "host=localhost port:8080 ratio:1.0" {
+		t.Errorf("Object transcode: %s", zb.String())
+	}
+	jb.Reset()
+	if err := TranscodeToJSON(&zb, &jb); err != nil || jb.String() != `{"host":"localhost","port":8080,"ratio":1}`+"\n" {
+		t.Errorf("Object to JSON: %s %v", jb.String(), err)
+	}
+
+	jb.Reset()
+	if err := TranscodeToJSON(strings.NewReader("# :s\na\nb_c"), &jb); err != nil || jb.String() != `["a","b c"]`+"\n" {
+		t.Errorf("List to JSON: %s %v", jb.String(), err)
+	}
+
+	if err := TranscodeFromJSON(strings.NewReader(`42`), io.Discard); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("Expected ErrUnsupportedType for scalar JSON, got %v", err)
+	}
+}
+
+func TestTimeFormats(t *testing.T) {
+	type Event struct {
+		Name string    `zoon:"name"`
+		At   time.Time `zoon:"at"`
+	}
+
+	base := time.Date(2025, 3, 1, 12, 30, 0, 250e6, time.UTC)
+	tests := []struct {
+		format TimeFormat
+		code   string
+		cell   string
+		trunc  time.Duration
+	}{
+		{TimeFormatRFC3339, "at:time", "2025-03-01T12:30:00Z", time.Second},
+		{TimeFormatUnix, "at:unix", "1740832200", time.Second},
+		{TimeFormatUnixMilli, "at:unixms", "1740832200250", time.Millisecond},
+	}
+	for _, tt := range tests {
+		events := []Event{{"a", base}, {"b", base.Add(time.Hour)}}
+		var buf bytes.Buffer
+		if err := NewEncoder(&buf, WithTimeFormat(tt.format)).Encode(events); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), tt.code) || !strings.Contains(buf.String(), tt.cell) {
+			t.Errorf("Format %d: expected %s and %s in %s", tt.format, tt.code, tt.cell, buf.String())
+		}
+		// The column code is enough; the decoder needs no option.
+		var dec []Event
+		if err := Unmarshal(buf.Bytes(), &dec); err != nil || !dec[0].At.Equal(base.Truncate(tt.trunc)) || !dec[1].At.Equal(base.Add(time.Hour).Truncate(tt.trunc)) {
+			t.Errorf("Format %d roundtrip failed for %s: %v %+v", tt.format, buf.String(), err, dec)
+		}
+
+		buf.Reset()
+		if err := NewEncoder(&buf, WithTimeFormat(tt.format)).Encode(events[:1]); err != nil {
+			t.Fatal(err)
+		}
+		dec = nil
+		if err := Unmarshal(buf.Bytes(), &dec); err != nil || !dec[0].At.Equal(base.Truncate(tt.trunc)) {
+			t.Errorf("Format %d constant roundtrip failed for %s: %v %+v", tt.format, buf.String(), err, dec)
+		}
+
+		buf.Reset()
+		if err := NewEncoder(&buf, WithTimeFormat(tt.format)).Encode(events[0]); err != nil {
+			t.Fatal(err)
+		}
+		var one Event
+		if err := NewDecoder(&buf, WithTimeFormat(tt.format)).Decode(&one); err != nil || !one.At.Equal(base.Truncate(tt.trunc)) {
+			t.Errorf("Format %d inline roundtrip failed: %v %+v", tt.format, err, one)
+		}
+	}
+}
+
+func TestDurationValues(t *testing.T) {
+	type Job struct {
+		Name    string        `zoon:"name"`
+		Timeout time.Duration `zoon:"timeout"`
+	}
+
+	jobs := []Job{{"a", 90 * time.Minute}, {"b", 0}, {"c", 1500 * time.Millisecond}}
+	enc, err := Marshal(jobs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(enc) != "# name:s timeout:dur\na 1h30m0s\nb 0s\nc 1.5s\n" {
+		t.Errorf("Unexpected duration encoding: %s", enc)
+	}
+	var dec []Job
+	if err := Unmarshal(enc, &dec); err != nil || !reflect.DeepEqual(jobs, dec) {
+		t.Errorf("Duration roundtrip failed for %s: %v %+v", enc, err, dec)
+	}
+
+	inline, err := Marshal(jobs[0])
+	if err != nil || string(inline) != "name=a timeout=1h30m0s" {
+		t.Errorf("Inline duration: %s %v", inline, err)
+	}
+	var one Job
+	if err := Unmarshal(inline, &one); err != nil || one != jobs[0] {
+		t.Errorf("Inline duration decode: %v %+v", err, one)
+	}
+
+	// Durations written as nanosecond integers still decode.
+	if err := Unmarshal([]byte("timeout:1000000000"), &one); err != nil || one.Timeout != time.Second {
+		t.Errorf("Nanosecond duration decode: %v %+v", err, one)
+	}
+	if err := Unmarshal([]byte("timeout=soon"), &one); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("Expected ErrInvalidFormat for bad duration, got %v", err)
+	}
+}
+
+func TestTranscodeCSV(t *testing.T) {
+	in := "id,name,price,active,region,note\n" +
+		"1,Widget,9.5,true,eu,\n" +
+		"2,Gadget,12,false,eu,fragile\n" +
+		"3,Gizmo,,true,eu,\n"
+
+	var buf bytes.Buffer
+	if err := TranscodeFromCSV(strings.NewReader(in), &buf); err != nil {
+		t.Fatal(err)
+	}
+	want := "# @region=eu active:b id:i+ name:s note:s price:f\n1 Widget \"\" 9.5\n0 Gadget fragile 12.0\n1 Gizmo \"\" ~\n"
+	if buf.String() != want {
+		t.Errorf("CSV transcode:\n got %q\nwant %q", buf.String(), want)
+	}
+
+	type Item struct {
+		ID     int      `zoon:"id"`
+		Name   string   `zoon:"name"`
+		Price  *float64 `zoon:"price"`
+		Active bool     `zoon:"active"`
+		Region string   `zoon:"region"`
+	}
+	var items []Item
+	if err := Unmarshal(buf.Bytes(), &items); err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 3 || *items[1].Price != 12 || items[2].Price != nil || !items[2].Active || items[0].Region != "eu" {
+		t.Errorf("Decoded CSV rows: %+v", items)
+	}
+
+	buf.Reset()
+	if err := TranscodeFromCSV(strings.NewReader("a;b\n1;x\n2;y\n"), &buf, WithCSVComma(';')); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "a:i") {
+		t.Errorf("Expected semicolon-separated columns: %s", buf.String())
+	}
+
+	if err := TranscodeFromCSV(strings.NewReader(""), io.Discard); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("Expected ErrInvalidFormat for empty CSV, got %v", err)
+	}
+}
+
+func TestDecodeTableIntoMaps(t *testing.T) {
+	data := "%a=addr\n# @%a.city=Paris id:i+ name:s role=Admin|User active:b\nAlice Admin 1\nBob User 0"
+
+	var rows []map[string]interface{}
+	if err := Unmarshal([]byte(data), &rows); err != nil {
+		t.Fatal(err)
+	}
+	want := []map[string]interface{}{
+		{"addr": map[string]any{"city": "Paris"}, "id": int64(1), "name": "Alice", "role": "Admin", "active": true},
+		{"addr": map[string]any{"city": "Paris"}, "id": int64(2), "name": "Bob", "role": "User", "active": false},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("Map rows:\n got %#v\nwant %#v", rows, want)
+	}
+
+	var typed []map[string]map[string]int
+	if err := Unmarshal([]byte("# a.x:i a.y:i\n1 2\n3 4"), &typed); err != nil {
+		t.Fatal(err)
+	}
+	if typed[1]["a"]["y"] != 4 {
+		t.Errorf("Typed nested map rows: %+v", typed)
+	}
+}
+
+func TestListValues(t *testing.T) {
+	type Doc struct {
+		Groups map[string][]int `zoon:"groups"`
+		Tags   []string         `zoon:"tags"`
+		Pair   [2]float64       `zoon:"pair"`
+	}
+
+	doc := Doc{
+		Groups: map[string][]int{"a": {1, 2}, "b": {3}},
+		Tags:   []string{"x y", "n", "q_r"},
+		Pair:   [2]float64{1.5, 2},
+	}
+	enc, err := Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(enc) != `groups:{a:[1 2] b:[3]} tags:[x_y n "q_r"] pair:[1.5 2.0]` {
+		t.Errorf("Unexpected list encoding: %s", enc)
+	}
+	var dec Doc
+	if err := Unmarshal(enc, &dec); err != nil || !reflect.DeepEqual(doc, dec) {
+		t.Errorf("List roundtrip failed for %s: %v %+v", enc, err, dec)
+	}
+
+	rows := []Doc{doc, {Tags: []string{"z"}}}
+	enc, err = Marshal(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decRows []Doc
+	if err := Unmarshal(enc, &decRows); err != nil || !reflect.DeepEqual(rows[1].Tags, decRows[1].Tags) || !reflect.DeepEqual(rows[0].Groups, decRows[0].Groups) {
+		t.Errorf("List table roundtrip failed for %s: %v %+v", enc, err, decRows)
+	}
+
+	var generic map[string]any
+	if err := Unmarshal([]byte("ids:[1 2 3] name=x"), &generic); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(generic["ids"], []any{int64(1), int64(2), int64(3)}) {
+		t.Errorf("Expected []any list, got %#v", generic["ids"])
+	}
+}
+
+func TestDecodeHeader(t *testing.T) {
+	data := "%a=addr\n# @%a.city=Paris @v:i=2 id:i+ name:s role!Admin|User|Guest\nAlice 0\nBob 2\n"
+
+	dec := NewDecoder(strings.NewReader(data))
+	fields, err := dec.DecodeHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ColumnDef{
+		{Name: "addr.city", TypeCode: "s", IsConstant: true, Value: "Paris"},
+		{Name: "v", TypeCode: "i", IsConstant: true, Value: "2"},
+		{Name: "id", TypeCode: "i", IsAutoInc: true},
+		{Name: "name", TypeCode: "s"},
+		{Name: "role", TypeCode: "s", Indexed: true, Options: []string{"Admin", "User", "Guest"}},
+	}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("Header fields:\n got %+v\nwant %+v", fields, want)
+	}
+
+	type User struct {
+		ID   int    `zoon:"id"`
+		Name string `zoon:"name"`
+		Role string `zoon:"role"`
+	}
+	var users []User
+	if err := dec.Decode(&users); err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 2 || users[1] != (User{2, "Bob", "Guest"}) {
+		t.Errorf("Rows after DecodeHeader: %+v", users)
+	}
+
+	dec.Reset(strings.NewReader(data))
+	if _, err := dec.DecodeHeader(); err != nil {
+		t.Fatal(err)
+	}
+	sc := dec.Scanner(User{})
+	var n int
+	for sc.Scan() {
+		n++
+	}
+	if sc.Err() != nil || n != 2 {
+		t.Errorf("Scanner after DecodeHeader: %d rows, %v", n, sc.Err())
+	}
+
+	dec.Reset(strings.NewReader("host=x"))
+	if _, err := dec.DecodeHeader(); err == nil {
+		t.Error("Expected an error for input without a header")
+	}
+}
+
+func TestHeterogeneousMapRows(t *testing.T) {
+	rows := []map[string]any{{"a": int64(1)}, {"b": int64(2)}}
+	enc, err := Marshal(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(enc) != "# a:i b:i\n1 ~\n~ 2\n" {
+		t.Errorf("Expected union header with ~ fills: %q", enc)
+	}
+	var dec []map[string]any
+	if err := Unmarshal(enc, &dec); err != nil || !reflect.DeepEqual(rows, dec) {
+		t.Errorf("Map rows roundtrip failed for %s: %v %#v", enc, err, dec)
+	}
+
+	rows = []map[string]any{{"a": int64(1), "kind": "x"}, {"b": "y", "kind": "x"}, {"a": int64(3), "c": true, "kind": "x"}}
+	enc, err = Marshal(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec = nil
+	if err := Unmarshal(enc, &dec); err != nil || !reflect.DeepEqual(rows, dec) {
+		t.Errorf("Map rows with constant roundtrip failed for %s: %v %#v", enc, err, dec)
+	}
+}
+
+func TestColumnSeparator(t *testing.T) {
+	type Row struct {
+		ID    int               `zoon:"id"`
+		Name  string            `zoon:"name"`
+		Note  string            `zoon:"note"`
+		Attrs map[string]string `zoon:"attrs"`
+	}
+
+	rows := []Row{
+		{1, "Ada Lovelace", "snake_case", map[string]string{"k": "a b"}},
+		{2, "Bob", "tab\there", nil},
+		{3, " padded", "", map[string]string{"k": "c"}},
+	}
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf, WithColumnSeparator('\t'), WithInlineMaps(true)).Encode(rows); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(buf.String(), "\n")
+	if lines[1] != "{k=\"a b\"}\tAda Lovelace\tsnake_case" {
+		t.Errorf("Unexpected tab-separated row: %q", lines[1])
+	}
+
+	var dec []Row
+	if err := NewDecoder(&buf, WithColumnSeparator('\t')).Decode(&dec); err != nil || !reflect.DeepEqual(rows, dec) {
+		t.Errorf("Tab separator roundtrip failed: %v\n got %+v\nwant %+v", err, dec, rows)
+	}
+}
+
+func TestRawValue(t *testing.T) {
+	type Event struct {
+		Kind    string   `zoon:"kind"`
+		Payload RawValue `zoon:"payload"`
+	}
+
+	data := "# kind:s payload:s\nclick {x:1 y:2}\nkey \"a b\"\nidle ~\nscroll 40\n"
+	var events []Event
+	if err := Unmarshal([]byte(data), &events); err != nil {
+		t.Fatal(err)
+	}
+	want := []RawValue{RawValue("{x:1 y:2}"), RawValue(`"a b"`), nil, RawValue("40")}
+	for i, ev := range events {
+		if !bytes.Equal(ev.Payload, want[i]) || (want[i] == nil) != (ev.Payload == nil) {
+			t.Errorf("Row %d payload: got %q want %q", i, ev.Payload, want[i])
+		}
+	}
+
+	enc, err := Marshal(events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(enc) != data {
+		t.Errorf("RawValue should be written verbatim:\n got %q\nwant %q", enc, data)
+	}
+
+	var click struct {
+		X int `zoon:"x"`
+		Y int `zoon:"y"`
+	}
+	p := events[0].Payload
+	if err := Unmarshal(p[1:len(p)-1], &click); err != nil || click.Y != 2 {
+		t.Errorf("Deferred decode: %v %+v", err, click)
+	}
+
+	inline, err := Marshal(events[0])
+	if err != nil || string(inline) != "kind=click payload:{x:1 y:2}" {
+		t.Errorf("Inline RawValue: %s %v", inline, err)
+	}
+	var ev Event
+	if err := Unmarshal(inline, &ev); err != nil || string(ev.Payload) != "{x:1 y:2}" {
+		t.Errorf("Inline RawValue decode: %v %q", err, ev.Payload)
+	}
+}
+
+func TestInlineParserQuotedValues(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []inlinePair
+	}{
+		{`name="a b c" n:1`, []inlinePair{{"name", "=", `"a b c"`}, {"n", ":", "1"}}},
+		{`a="x: y=z" b=c`, []inlinePair{{"a", "=", `"x: y=z"`}, {"b", "=", "c"}}},
+		{`a="{not an object}" b:2`, []inlinePair{{"a", "=", `"{not an object}"`}, {"b", ":", "2"}}},
+		{`a="say \"hi there\"" b:2`, []inlinePair{{"a", "=", `"say \"hi there\""`}, {"b", ":", "2"}}},
+		{`o:{k="a b" j="}"} z:1`, []inlinePair{{"o", ":", `{k="a b" j="}"}`}, {"z", ":", "1"}}},
+		{`o:{k={m="{ ["}} l:["a ]" b]`, []inlinePair{{"o", ":", `{k={m="{ ["}}`}, {"l", ":", `["a ]" b]`}}},
+		{`  a="  "   b:1  `, []inlinePair{{"a", "=", `"  "`}, {"b", ":", "1"}}},
+	}
+	for _, tt := range tests {
+		p := &inlineParser{input: tt.input}
+		got, err := p.parse()
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parse(%q):\n got %q %v\nwant %q", tt.input, got, err, tt.want)
+		}
+	}
+
+	type Inner struct {
+		K string `zoon:"k"`
+		J string `zoon:"j"`
+	}
+	type Doc struct {
+		Name string `zoon:"name"`
+		O    Inner  `zoon:"o"`
+		Z    int    `zoon:"z"`
+	}
+	var doc Doc
+	if err := Unmarshal([]byte(`name="a {b} c" o:{k="x y" j="}{"} z:3`), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc != (Doc{"a {b} c", Inner{"x y", "}{"}, 3}) {
+		t.Errorf("Quoted inline decode: %+v", doc)
+	}
+	enc, err := Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back Doc
+	if err := Unmarshal(enc, &back); err != nil || back != doc {
+		t.Errorf("Quoted inline roundtrip failed for %s: %v %+v", enc, err, back)
+	}
+}
+
+func TestListFields(t *testing.T) {
+	type Player struct {
+		Name   string    `zoon:"name"`
+		Tags   []string  `zoon:"tags"`
+		Scores []float64 `zoon:"scores"`
+	}
+
+	players := []Player{
+		{"a", []string{"fast", "left handed", "x,y", `say "hi"`}, []float64{9.5, 7}},
+		{"b", []string{}, nil},
+		{"c", []string{"slow"}, []float64{1.25}},
+	}
+	enc, err := Marshal(players)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dec []Player
+	if err := Unmarshal(enc, &dec); err != nil || !reflect.DeepEqual(players, dec) {
+		t.Errorf("List fields roundtrip failed for %s: %v\n got %#v\nwant %#v", enc, err, dec, players)
+	}
+
+	var p Player
+	if err := Unmarshal([]byte(`name=d tags:[a,"b c", d] scores:[1,2.5]`), &p); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(p.Tags, []string{"a", "b c", "d"}) || !reflect.DeepEqual(p.Scores, []float64{1, 2.5}) {
+		t.Errorf("Comma-separated lists: %+v", p)
+	}
+
+	brackets := []Player{{"e", []string{"a]b", "[c", "x]"}, nil}, {"f", []string{"g"}, []float64{1}}}
+	enc, err = Marshal(brackets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec = nil
+	if err := Unmarshal(enc, &dec); err != nil || !reflect.DeepEqual(brackets, dec) {
+		t.Errorf("Bracket items roundtrip failed for %s: %v\n got %#v", enc, err, dec)
+	}
+	enc, err = Marshal(brackets[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	p = Player{}
+	if err := Unmarshal(enc, &p); err != nil || !reflect.DeepEqual(p, brackets[0]) {
+		t.Errorf("Inline bracket items roundtrip failed for %s: %v %#v", enc, err, p)
+	}
+	nested, err := Marshal(map[string]any{"m": [][]string{{"a", "b]"}, {"c"}}})
+	if err != nil || string(nested) != `m:[[a "b]"] [c]]` {
+		t.Errorf("Nested lists: %s %v", nested, err)
+	}
+}
+
+func TestMultipleDocuments(t *testing.T) {
+	type User struct {
+		ID   int    `zoon:"id"`
+		Name string `zoon:"name"`
+	}
+	type Product struct {
+		SKU   string  `zoon:"sku"`
+		Price float64 `zoon:"price"`
+	}
+	type Config struct {
+		Host string `zoon:"host"`
+	}
+
+	users := []User{{1, "Alice"}, {2, "Bob"}}
+	products := []Product{{"a1", 9.5}, {"b2", 3}}
+	cfg := Config{"localhost"}
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	if err := enc.Encode(cfg, users); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Encode(products); err != nil {
+		t.Fatal(err)
+	}
+	want := "host=localhost\n---\n# id:i+ name:s\nAlice\nBob\n---\n# price:f sku:s\n9.5 a1\n3.0 b2\n"
+	if buf.String() != want {
+		t.Errorf("Multi-document encoding:\n got %q\nwant %q", buf.String(), want)
+	}
+
+	dec := NewDecoder(&buf)
+	var gotCfg Config
+	var gotUsers []User
+	var gotProducts []Product
+	for i, v := range []any{&gotCfg, &gotUsers, &gotProducts} {
+		if !dec.More() {
+			t.Fatalf("More reported no document %d", i)
+		}
+		if err := dec.Decode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if dec.More() {
+		t.Error("More reported a document past the end")
+	}
+	if gotCfg != cfg || !reflect.DeepEqual(gotUsers, users) || !reflect.DeepEqual(gotProducts, products) {
+		t.Errorf("Multi-document decoding: %+v %+v %+v", gotCfg, gotUsers, gotProducts)
+	}
+
+	// Two blank lines separate documents too, and a RowScanner stops at
+	// the end of its own.
+	dec = NewDecoder(strings.NewReader("# id:i name:s\n1 Alice\n\n\n# sku:s price:f\na1 9.5\n"))
+	sc := dec.Scanner(User{})
+	n := 0
+	for sc.Scan() {
+		n++
+	}
+	if sc.Err() != nil || n != 1 {
+		t.Errorf("Scanner over first document: %d rows, %v", n, sc.Err())
+	}
+	gotProducts = nil
+	if err := dec.Decode(&gotProducts); err != nil || len(gotProducts) != 1 || gotProducts[0].SKU != "a1" {
+		t.Errorf("Decode after Scanner: %v %+v", err, gotProducts)
+	}
+}
+
+func TestDecodeStream(t *testing.T) {
+	type Record struct {
+		Level string `zoon:"level"`
+		Code  int    `zoon:"code"`
+	}
+
+	input := "level=info code:1\n\nlevel=warn code:2\n\n// third\nlevel=error code:3\n"
+	dec := NewDecoder(strings.NewReader(input))
+	var got []Record
+	for {
+		var r Record
+		err := dec.Decode(&r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, r)
+	}
+	want := []Record{{"info", 1}, {"warn", 2}, {"error", 3}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Streamed records: %+v", got)
+	}
+
+	var r Record
+	if err := NewDecoder(strings.NewReader("")).Decode(&r); err != io.EOF {
+		t.Errorf("Expected io.EOF for empty input, got %v", err)
+	}
+	if err := Unmarshal(nil, &r); err != nil {
+		t.Errorf("Unmarshal of empty data should succeed, got %v", err)
+	}
+}
+
+func TestBoolLists(t *testing.T) {
+	type Flags struct {
+		Name string `zoon:"name"`
+		Bits []bool `zoon:"bits"`
+	}
+
+	flags := Flags{"f", []bool{true, false, true}}
+	tests := []struct {
+		style BoolStyle
+		want  string
+	}{
+		{BoolStyleDefault, "name=f bits:[y n y]"},
+		{BoolStyleYesNo, "name=f bits:[y n y]"},
+		{BoolStyleTrueFalse, "name=f bits:[true false true]"},
+		{BoolStyleOneZero, "name=f bits:[1 0 1]"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := NewEncoder(&buf, WithBoolStyle(tt.style)).Encode(flags); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != tt.want {
+			t.Errorf("Style %d: got %q want %q", tt.style, buf.String(), tt.want)
+		}
+		var dec Flags
+		if err := Unmarshal(buf.Bytes(), &dec); err != nil || !reflect.DeepEqual(flags, dec) {
+			t.Errorf("Style %d roundtrip failed: %v %+v", tt.style, err, dec)
+		}
+	}
+
+	rows := []Flags{flags, {"g", []bool{false}}}
+	enc, err := Marshal(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dec []Flags
+	if err := Unmarshal(enc, &dec); err != nil || !reflect.DeepEqual(rows, dec) {
+		t.Errorf("Bool list table roundtrip failed for %s: %v %+v", enc, err, dec)
+	}
+}
+
+func TestDecoderMore(t *testing.T) {
+	input := "\n// leading comment\nn:1\n\nn:2\n---\n# n:i\n3\n4\n\n\n   \n"
+	dec := NewDecoder(strings.NewReader(input))
+
+	var got []int
+	for dec.More() {
+		// Peeking twice must not skip a document.
+		if !dec.More() {
+			t.Fatal("More changed its answer without a Decode")
+		}
+		if len(got) == 2 {
+			var rows []struct {
+				N int `zoon:"n"`
+			}
+			if err := dec.Decode(&rows); err != nil {
+				t.Fatal(err)
+			}
+			for _, r := range rows {
+				got = append(got, r.N)
+			}
+			continue
+		}
+		var v struct {
+			N int `zoon:"n"`
+		}
+		if err := dec.Decode(&v); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, v.N)
+	}
+	if !reflect.DeepEqual(got, []int{1, 2, 3, 4}) {
+		t.Errorf("Documents read with More: %v", got)
+	}
+	var v struct{}
+	if err := dec.Decode(&v); err != io.EOF {
+		t.Errorf("Expected io.EOF after More returned false, got %v", err)
+	}
+}
+
+func TestEncoderTuningOptions(t *testing.T) {
+	type Server struct {
+		Host   string `zoon:"host"`
+		Port   int    `zoon:"port"`
+		Region string `zoon:"region"`
+	}
+	type Row struct {
+		ID     int    `zoon:"id"`
+		Env    string `zoon:"env"`
+		Tier   string `zoon:"tier"`
+		Server Server `zoon:"datacenter"`
+	}
+	rows := []Row{
+		{1, "prod", "web", Server{"a", 80, "eu"}},
+		{2, "prod", "db", Server{"b", 80, "us"}},
+		{3, "prod", "web", Server{"c", 80, "ap"}},
+	}
+
+	tests := []struct {
+		name string
+		opts []EncoderOption
+		want string
+	}{
+		{"default", nil, "%d=datacenter\n# @%d.port:80 @env=prod %d.host:s %d.region:s id:i+ tier=db|web\n"},
+		{"no aliases", []EncoderOption{WithAliases(false)}, "# @datacenter.port:80 @env=prod datacenter.host:s datacenter.region:s id:i+ tier=db|web\n"},
+		{"no hoisting", []EncoderOption{WithAliases(false), WithConstantHoisting(false)}, "# datacenter.host:s datacenter.port:i datacenter.region:s env=prod id:i+ tier=db|web\n"},
+		{"min constant rows", []EncoderOption{WithAliases(false), WithMinConstantRows(4)}, "# datacenter.host:s datacenter.port:i datacenter.region:s env=prod id:i+ tier=db|web\n"},
+		{"enum max options", []EncoderOption{WithAliases(false), WithEnumMaxOptions(1)}, "# @datacenter.port:80 @env=prod datacenter.host:s datacenter.region:s id:i+ tier:s\n"},
+		{"enum min ratio", []EncoderOption{WithAliases(false), WithEnumMinRatio(2)}, "# @datacenter.port:80 @env=prod datacenter.host:s datacenter.region:s id:i+ tier:s\n"},
+		{"text threshold", []EncoderOption{WithAliases(false), WithEnumMaxOptions(1), WithTextLengthThreshold(1)}, "# @datacenter.port:80 @env=prod datacenter.host:s datacenter.region:t id:i+ tier:t\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := NewEncoder(&buf, tt.opts...).Encode(rows); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(buf.String(), tt.want) {
+			t.Errorf("%s: got\n%s\nwant header\n%s", tt.name, buf.String(), tt.want)
+		}
+		var dec []Row
+		if err := Unmarshal(buf.Bytes(), &dec); err != nil || !reflect.DeepEqual(dec, rows) {
+			t.Errorf("%s: roundtrip failed: %v\n got %+v\nwant %+v", tt.name, err, dec, rows)
+		}
+	}
+}
+
+func TestNullSymbol(t *testing.T) {
+	type Row struct {
+		Name string  `zoon:"name"`
+		Note *string `zoon:"note"`
+	}
+	null, dash := "null", "-"
+	rows := []Row{{"a", nil}, {"b", &null}, {"c", &dash}}
+
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf, WithNullSymbol("null")).Encode(rows); err != nil {
+		t.Fatal(err)
+	}
+	want := "# name:s note:s\na null\nb \"null\"\nc -\n"
+	if buf.String() != want {
+		t.Errorf("Null symbol encode:\n got %q\nwant %q", buf.String(), want)
+	}
+	var dec []Row
+	if err := NewDecoder(&buf, WithNullSymbol("null")).Decode(&dec); err != nil || !reflect.DeepEqual(dec, rows) {
+		t.Errorf("Null symbol roundtrip failed: %v\n got %+v\nwant %+v", err, dec, rows)
+	}
+}
+
+func TestRegisterDecodeFunc(t *testing.T) {
+	type Color struct{ R, G, B uint8 }
+	type Shape struct {
+		Name  string `zoon:"name"`
+		Color Color  `zoon:"color"`
+	}
+	parseColor := func(token string) (any, error) {
+		var c Color
+		if _, err := fmt.Sscanf(token, "#%02x%02x%02x", &c.R, &c.G, &c.B); err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+
+	data := "# name:s color:s\ncircle #ff8000\nsquare #00ff7f\n"
+	dec := NewDecoder(strings.NewReader(data))
+	dec.RegisterDecodeFunc(reflect.TypeOf(Color{}), parseColor)
+	var shapes []Shape
+	if err := dec.Decode(&shapes); err != nil {
+		t.Fatal(err)
+	}
+	want := []Shape{{"circle", Color{0xff, 0x80, 0}}, {"square", Color{0, 0xff, 0x7f}}}
+	if !reflect.DeepEqual(shapes, want) {
+		t.Errorf("Decode with registered func: got %+v want %+v", shapes, want)
+	}
+
+	dec.Reset(strings.NewReader("name=dot color=#0000ff"))
+	var shape Shape
+	if err := dec.Decode(&shape); err != nil || shape.Color != (Color{0, 0, 0xff}) {
+		t.Errorf("Inline decode with registered func: %v %+v", err, shape)
+	}
+
+	dec.Reset(strings.NewReader("name=dot color=blue"))
+	if err := dec.Decode(&shape); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("Expected ErrInvalidFormat from a failing decode func, got %v", err)
+	}
+}
+
+func TestBase64Bytes(t *testing.T) {
+	type Image struct {
+		Name string `zoon:"name"`
+		Img  []byte `zoon:"img"`
+	}
+
+	data := []Image{
+		{"a", []byte{0, 1, 2, 0xff}},
+		{"b", []byte{}},
+		{"c", nil},
+	}
+	enc, err := Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# img:base64 name:s\nAAEC/w== a\n\"\" b\n~ c\n"
+	if string(enc) != want {
+		t.Errorf("Base64 column:\n got %q\nwant %q", enc, want)
+	}
+	var dec []Image
+	if err := Unmarshal(enc, &dec); err != nil || !reflect.DeepEqual(data, dec) {
+		t.Errorf("Base64 roundtrip failed: %v\n got %#v\nwant %#v", err, dec, data)
+	}
+
+	// Untyped columns and inline values still decode into []byte as base64,
+	// and a typed column decodes to []byte in a map.
+	var img Image
+	if err := Unmarshal([]byte("name=x img=AAEC/w=="), &img); err != nil || !bytes.Equal(img.Img, data[0].Img) {
+		t.Errorf("Inline base64 decode: %v %v", err, img.Img)
+	}
+	var rows []map[string]any
+	if err := Unmarshal(enc, &rows); err != nil || !bytes.Equal(rows[0]["img"].([]byte), data[0].Img) {
+		t.Errorf("Base64 column into map: %v %#v", err, rows)
+	}
+}
+
+func TestRecursiveTree(t *testing.T) {
+	type Node struct {
+		Value    int    `zoon:"value"`
+		Children []Node `zoon:"children"`
+	}
+	tree := Node{1, []Node{{2, nil}, {3, []Node{{4, nil}, {5, nil}}}}}
+
+	enc, err := Marshal(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "value:1 children:[{value:2 children:~} {value:3 children:[{value:4 children:~} {value:5 children:~}]}]"
+	if string(enc) != want {
+		t.Errorf("Tree encode:\n got %s\nwant %s", enc, want)
+	}
+	var dec Node
+	if err := Unmarshal(enc, &dec); err != nil || !reflect.DeepEqual(dec, tree) {
+		t.Errorf("Tree roundtrip failed: %v\n got %+v\nwant %+v", err, dec, tree)
+	}
+
+	forest := []Node{tree, {6, nil}}
+	if enc, err = Marshal(forest); err != nil {
+		t.Fatal(err)
+	}
+	var decForest []Node
+	if err := Unmarshal(enc, &decForest); err != nil || !reflect.DeepEqual(decForest, forest) {
+		t.Errorf("Forest roundtrip failed: %v\n got %+v\nwant %+v", err, decForest, forest)
+	}
+}
+
+func TestCyclicValue(t *testing.T) {
+	type Node struct {
+		Name   string `zoon:"name"`
+		Parent *Node  `zoon:"parent"`
+	}
+	root := &Node{Name: "root"}
+	root.Parent = root
+
+	if _, err := Marshal(root); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("Expected ErrUnsupportedType for a cyclic inline value, got %v", err)
+	}
+	if _, err := Marshal([]*Node{root, {Name: "leaf"}}); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("Expected ErrUnsupportedType for a cyclic table row, got %v", err)
+	}
+	if _, err := DeriveSchema([]*Node{root}); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("Expected ErrUnsupportedType deriving a schema from a cyclic row, got %v", err)
+	}
+}
+
+func TestListColumnTypes(t *testing.T) {
+	type Team struct {
+		Name    string   `zoon:"name"`
+		Members []string `zoon:"members"`
+		Scores  []int    `zoon:"scores"`
+		Extra   []any    `zoon:"extra"`
+	}
+
+	teams := []Team{
+		{"red", []string{"alice", "bob carol"}, []int{3, -1}, []any{"x", int64(1)}},
+		{"blue", []string{"dave"}, nil, nil},
+	}
+	enc, err := Marshal(teams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# extra:s members:s[] name:s scores:i[]\n[x 1] [alice bob_carol] red [3 -1]\n~ [dave] blue ~\n"
+	if string(enc) != want {
+		t.Errorf("List column types:\n got %q\nwant %q", enc, want)
+	}
+	var dec []Team
+	if err := Unmarshal(enc, &dec); err != nil || !reflect.DeepEqual(teams, dec) {
+		t.Errorf("List columns roundtrip failed: %v\n got %#v\nwant %#v", err, dec, teams)
+	}
+
+	// The element code types the items of untyped targets.
+	var rows []map[string]any
+	if err := Unmarshal([]byte("# ids:i[] codes:s[]\n[1 2] [7 y]\n"), &rows); err != nil {
+		t.Fatal(err)
+	}
+	wantRow := map[string]any{"ids": []any{int64(1), int64(2)}, "codes": []any{"7", "y"}}
+	if !reflect.DeepEqual(rows[0], wantRow) {
+		t.Errorf("Typed lists into a map: got %#v want %#v", rows[0], wantRow)
+	}
+}
+
+func TestMapKeyOrder(t *testing.T) {
+	days := []string{"mon", "tue", "wed", "thu", "fri", "sat", "sun"}
+	// Days come in week order, ahead of any other key.
+	rank := func(k string) int {
+		if i := slices.Index(days, k); i >= 0 {
+			return i
+		}
+		return len(days)
+	}
+	byDay := func(a, b string) bool { return rank(a) < rank(b) }
+
+	hours := map[string]int{"fri": 6, "mon": 8, "sun": 0, "wed": 7, "note": 1}
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf, WithMapKeyOrder(byDay)).Encode(hours); err != nil {
+		t.Fatal(err)
+	}
+	if want := "mon:8 wed:7 fri:6 sun:0 note:1"; buf.String() != want {
+		t.Errorf("Inline keys in custom order:\n got %q\nwant %q", buf.String(), want)
+	}
+
+	weeks := []map[string]int{
+		{"tue": 1, "mon": 2, "thu": 3},
+		{"tue": 1, "mon": 4, "thu": 5},
+	}
+	buf.Reset()
+	if err := NewEncoder(&buf, WithMapKeyOrder(byDay)).Encode(weeks); err != nil {
+		t.Fatal(err)
+	}
+	if want := "# @tue:1 mon:i thu:i\n2 3\n4 5\n"; buf.String() != want {
+		t.Errorf("Table columns in custom order:\n got %q\nwant %q", buf.String(), want)
+	}
+	var dec []map[string]int
+	if err := Unmarshal(buf.Bytes(), &dec); err != nil || !reflect.DeepEqual(dec, weeks) {
+		t.Errorf("Custom order roundtrip failed: %v %v", err, dec)
+	}
+}
+
+func TestEnumThreshold(t *testing.T) {
+	type Row struct {
+		Country string `zoon:"country"`
+	}
+	var rows []Row
+	for i := range 30 {
+		rows = append(rows, Row{fmt.Sprintf("c%02d", i%15)})
+	}
+	header := func(opts ...EncoderOption) string {
+		var buf bytes.Buffer
+		if err := NewEncoder(&buf, opts...).Encode(rows); err != nil {
+			t.Fatal(err)
+		}
+		var dec []Row
+		if err := Unmarshal(buf.Bytes(), &dec); err != nil || !reflect.DeepEqual(dec, rows) {
+			t.Errorf("Roundtrip failed: %v", err)
+		}
+		return strings.SplitN(buf.String(), "\n", 2)[0]
+	}
+
+	if got := header(); got != "# country:s" {
+		t.Errorf("15 values at the default cap: got %q", got)
+	}
+	if got := header(WithEnumThreshold(1, 20)); !strings.HasPrefix(got, "# country!c00|c01|") && !strings.HasPrefix(got, "# country=c00|c01|") {
+		t.Errorf("15 values with the cap raised: got %q", got)
+	}
+	if got := header(WithEnumThreshold(16, 20)); got != "# country:s" {
+		t.Errorf("15 values below the minimum: got %q", got)
+	}
+	if got := header(WithEnumMaxCardinality(15)); !strings.HasPrefix(got, "# country!c00|c01|") && !strings.HasPrefix(got, "# country=c00|c01|") {
+		t.Errorf("15 values with WithEnumMaxCardinality(15): got %q", got)
+	}
+	if got := header(WithEnumMaxCardinality(20), WithEnumMinCardinality(16)); got != "# country:s" {
+		t.Errorf("15 values below WithEnumMinCardinality(16): got %q", got)
+	}
+
+	small := []Row{{"no"}, {"se"}, {"no"}}
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf, WithEnumThreshold(1, 0)).Encode(small); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "# country:s\n") {
+		t.Errorf("Enums turned off: got %q", buf.String())
+	}
+}
+
+func TestConstantColumnConflict(t *testing.T) {
+	type Address struct {
+		City string `zoon:"city"`
+	}
+	type Person struct {
+		Name    string  `zoon:"name"`
+		Address Address `zoon:"addr"`
+	}
+	data := "%a=addr\n# @%a.city=Paris name:s addr.city:s\nAnn Oslo\n"
+
+	var lenient []Person
+	if err := Unmarshal([]byte(data), &lenient); err != nil {
+		t.Fatal(err)
+	}
+	if lenient[0].Address.City != "Oslo" {
+		t.Errorf("Expected the column to win when lenient, got %+v", lenient[0])
+	}
+
+	var strict []Person
+	err := NewDecoder(strings.NewReader(data), WithStrict(true)).Decode(&strict)
+	if !errors.Is(err, ErrInvalidFormat) || !strings.Contains(err.Error(), "addr.city") {
+		t.Errorf("Expected a constant/column conflict error, got %v", err)
+	}
+
+	sc := NewDecoder(strings.NewReader(data), WithStrict(true)).Scanner(Person{})
+	if sc.Scan() || !errors.Is(sc.Err(), ErrInvalidFormat) {
+		t.Errorf("Expected the scanner to report the conflict, got %v", sc.Err())
+	}
+}
+
+type logLevel int
+
+func (l logLevel) MarshalText() ([]byte, error) {
+	return []byte([]string{"debug", "info", "warn"}[l]), nil
+}
+
+func (l *logLevel) UnmarshalText(b []byte) error {
+	i := slices.Index([]string{"debug", "info", "warn"}, string(b))
+	if i < 0 {
+		return fmt.Errorf("unknown level %q", b)
+	}
+	*l = logLevel(i)
+	return nil
+}
+
+func TestTextMarshaler(t *testing.T) {
+	type Host struct {
+		IP    net.IP      `zoon:"ip"`
+		Addr  netip.Addr  `zoon:"addr"`
+		Level logLevel    `zoon:"level"`
+		Peer  *netip.Addr `zoon:"peer"`
+	}
+	peer := netip.MustParseAddr("fe80::1")
+	hosts := []Host{
+		{net.ParseIP("10.0.0.1"), netip.MustParseAddr("192.168.1.1"), 2, &peer},
+		{net.ParseIP("10.0.0.2"), netip.MustParseAddr("::1"), 0, nil},
+	}
+
+	enc, err := Marshal(hosts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# addr:s ip:s level:s peer:s\n192.168.1.1 10.0.0.1 warn fe80::1\n::1 10.0.0.2 debug ~\n"
+	if string(enc) != want {
+		t.Errorf("TextMarshaler columns:\n got %q\nwant %q", enc, want)
+	}
+	var dec []Host
+	if err := Unmarshal(enc, &dec); err != nil || !reflect.DeepEqual(dec, hosts) {
+		t.Errorf("TextMarshaler roundtrip failed: %v\n got %+v\nwant %+v", err, dec, hosts)
+	}
+
+	var h Host
+	if err := Unmarshal([]byte("ip=::2 level=info"), &h); err != nil || h.Level != 1 || h.IP.String() != "::2" {
+		t.Errorf("Inline TextUnmarshaler: %v %+v", err, h)
+	}
+	if err := Unmarshal([]byte("level=fatal"), &h); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("Expected ErrInvalidFormat from UnmarshalText, got %v", err)
+	}
+}
+
+func TestDisallowUnknownFields(t *testing.T) {
+	type Address struct {
+		City string `zoon:"city"`
+	}
+	type Person struct {
+		Name    string  `zoon:"name"`
+		Address Address `zoon:"addr"`
+	}
+
+	good := "# name:s addr.city:s\nAnn Oslo\n"
+	dec := NewDecoder(strings.NewReader(good))
+	dec.DisallowUnknownFields()
+	var people []Person
+	if err := dec.Decode(&people); err != nil || people[0].Address.City != "Oslo" {
+		t.Errorf("Known columns should decode: %v %+v", err, people)
+	}
+
+	typo := "# name:s addr.cty:s\nAnn Oslo\n"
+	dec = NewDecoder(strings.NewReader(typo))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&people)
+	if !errors.Is(err, ErrInvalidFormat) || !strings.Contains(err.Error(), `"addr.cty"`) {
+		t.Errorf("Expected an error naming addr.cty, got %v", err)
+	}
+
+	dec = NewDecoder(strings.NewReader(typo))
+	dec.DisallowUnknownFields()
+	var rows []map[string]any
+	if err := dec.Decode(&rows); err != nil {
+		t.Errorf("Maps accept any key: %v", err)
+	}
+}
+
+type EmbeddedBase struct {
+	ID      int       `zoon:"id"`
+	Created time.Time `zoon:"created"`
+	Name    string    `zoon:"name"`
+}
+
+type EmbeddedAudit struct {
+	By string `zoon:"by"`
+}
+
+type embeddedNote struct {
+	Note string `zoon:"note"`
+}
+
+func TestEmbeddedStructs(t *testing.T) {
+	type Meta struct {
+		Tag string `zoon:"tag"`
+	}
+	type Row struct {
+		EmbeddedBase
+		*EmbeddedAudit
+		embeddedNote
+		Meta  `zoon:"meta"`
+		Name  string `zoon:"name"` // shadows EmbeddedBase.Name
+		Score int    `zoon:"score"`
+	}
+
+	created := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	rows := []Row{
+		{EmbeddedBase{1, created, "hidden"}, &EmbeddedAudit{"ann"}, embeddedNote{"first"}, Meta{"a"}, "alpha", 10},
+		{EmbeddedBase{2, created, "hidden"}, nil, embeddedNote{"second"}, Meta{"b"}, "beta", 20},
+	}
+	enc, err := Marshal(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# @created=2025-01-02T03:04:05Z by:s id:i+ meta.tag:s name:s note:s score:i\nann a alpha first 10\n~ b beta second 20\n"
+	if string(enc) != want {
+		t.Errorf("Embedded fields:\n got %q\nwant %q", enc, want)
+	}
+
+	var dec []Row
+	if err := Unmarshal(enc, &dec); err != nil {
+		t.Fatal(err)
+	}
+	for i := range rows {
+		// The shadowed field is not written, so it can't come back.
+		rows[i].EmbeddedBase.Name = ""
+	}
+	rows[1].EmbeddedAudit = nil
+	if !reflect.DeepEqual(dec, rows) {
+		t.Errorf("Embedded roundtrip:\n got %+v\nwant %+v", dec, rows)
+	}
+
+	inline, err := Marshal(rows[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "id:1 created=2025-01-02T03:04:05Z by=ann note=first meta:{tag=a} name=alpha score:10"; string(inline) != want {
+		t.Errorf("Embedded inline fields:\n got %s\nwant %s", inline, want)
+	}
+	var one Row
+	if err := Unmarshal(inline, &one); err != nil || !reflect.DeepEqual(one, rows[0]) {
+		t.Errorf("Embedded inline roundtrip: %v\n got %+v\nwant %+v", err, one, rows[0])
+	}
+}
+
+func TestIntBase(t *testing.T) {
+	type Object struct {
+		ID   int64  `zoon:"id"`
+		Hash uint64 `zoon:"hash"`
+		Size int    `zoon:"size"`
+	}
+	objects := []Object{
+		{9007199254740993, 18446744073709551615, -5},
+		{1234567890123, 42, 10},
+		{-36, 0, 35},
+	}
+
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf, WithIntBase(36)).Encode(objects); err != nil {
+		t.Fatal(err)
+	}
+	want := "# hash:u36 id:i36 size:i36\n3w5e11264sgsf 2gosa7pa2gx -5\n16 fr5hugnf a\n0 -10 z\n"
+	if buf.String() != want {
+		t.Errorf("Base 36 columns:\n got %q\nwant %q", buf.String(), want)
+	}
+	var dec []Object
+	if err := Unmarshal(buf.Bytes(), &dec); err != nil || !reflect.DeepEqual(dec, objects) {
+		t.Errorf("Base 36 roundtrip failed: %v\n got %+v\nwant %+v", err, dec, objects)
+	}
+
+	if err := Unmarshal([]byte("# id:i36\n!!\n"), &dec); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("Expected ErrInvalidFormat for a bad base 36 value, got %v", err)
+	}
+}
+
+func TestDeepMapPaths(t *testing.T) {
+	var counts []map[string]map[string]map[string]int
+	if err := Unmarshal([]byte("# region.dc.count:i region.dc.racks:i\n3 12\n5 20\n"), &counts); err != nil {
+		t.Fatal(err)
+	}
+	if counts[1]["region"]["dc"]["count"] != 5 || counts[1]["region"]["dc"]["racks"] != 20 {
+		t.Errorf("Three-level map path: %+v", counts)
+	}
+
+	type DC struct {
+		Count int    `zoon:"count"`
+		Name  string `zoon:"name"`
+	}
+	var sites map[string]map[string]DC
+	if err := Unmarshal([]byte("eu.dc.count:3 eu.dc.name=ams eu.edge.count:1"), &sites); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]map[string]DC{"eu": {"dc": {3, "ams"}, "edge": {1, ""}}}
+	if !reflect.DeepEqual(sites, want) {
+		t.Errorf("Struct values in nested maps:\n got %+v\nwant %+v", sites, want)
+	}
+}
+
+func TestMalformedInline(t *testing.T) {
+	type Inner struct {
+		A int `zoon:"a"`
+		B int `zoon:"b"`
+	}
+	type Doc struct {
+		Name string   `zoon:"name"`
+		K    Inner    `zoon:"k"`
+		Tags []string `zoon:"tags"`
+	}
+
+	bad := []struct{ input, want string }{
+		{"name=x k:{a:1", `"{a:1"`},
+		{"name=x k:{a:{b:1}", `"{a:{b:1}"`},
+		{"k:{a:1 b:} name=x", "b:"},
+		{"tags:[a b", `"[a b"`},
+		{`name="open`, `"\"open"`},
+		{"name=x k:", "k:"},
+		{"name= k:{a:1}", "name="},
+		{":5 name=x", `":5 name=x"`},
+		{"name x", "name"},
+	}
+	for _, tt := range bad {
+		var doc Doc
+		err := Unmarshal([]byte(tt.input), &doc)
+		if !errors.Is(err, ErrInvalidFormat) || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%q: expected ErrInvalidFormat mentioning %s, got %v", tt.input, tt.want, err)
+		}
+	}
+
+	var rows []Doc
+	err := Unmarshal([]byte("# name:s k:s\nx {a:1\n"), &rows)
+	if !errors.Is(err, ErrInvalidFormat) || !strings.Contains(err.Error(), `"{a:1"`) {
+		t.Errorf("Expected an unbalanced braces error for a table cell, got %v", err)
+	}
+
+	var doc Doc
+	if err := Unmarshal([]byte(`name="a}b" k:{a:1 b:2} tags:["x]" y]`), &doc); err != nil {
+		t.Errorf("Braces inside quotes are not structure: %v", err)
+	}
+}
+
+func TestHeaderMetadata(t *testing.T) {
+	data := "source: crm export\nexported:\"2025-01-02 10:00\"\n# id:i+ name:s\nAlice\nBob\n"
+
+	type User struct {
+		ID   int    `zoon:"id"`
+		Name string `zoon:"name"`
+	}
+	dec := NewDecoder(strings.NewReader(data))
+	var users []User
+	if err := dec.Decode(&users); err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 2 || users[1] != (User{2, "Bob"}) {
+		t.Errorf("Rows after metadata: %+v", users)
+	}
+	want := map[string]string{"source": "crm export", "exported": "2025-01-02 10:00"}
+	if !reflect.DeepEqual(dec.Metadata(), want) {
+		t.Errorf("Metadata() = %v, want %v", dec.Metadata(), want)
+	}
+
+	var cfg map[string]any
+	dec.Reset(strings.NewReader("host=localhost port:3000\n"))
+	if err := dec.Decode(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if dec.Metadata() != nil || cfg["host"] != "localhost" {
+		t.Errorf("Inline document: metadata %v, value %v", dec.Metadata(), cfg)
+	}
+}
+
+func TestMarshalPaged(t *testing.T) {
+	type Task struct {
+		ID    int    `zoon:"id"`
+		Title string `zoon:"title"`
+		Done  bool   `zoon:"done"`
+	}
+	var tasks []Task
+	for i := 1; i <= 10; i++ {
+		tasks = append(tasks, Task{i, fmt.Sprintf("task-%d", i), i%3 == 0})
+	}
+
+	pages, err := MarshalPaged(tasks, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 3 {
+		t.Fatalf("Expected 3 pages, got %d", len(pages))
+	}
+
+	var all []Task
+	for i, page := range pages {
+		var got []Task
+		if err := Unmarshal(page, &got); err != nil {
+			t.Fatalf("Page %d: %v\n%s", i, err, page)
+		}
+		if want := min(4, 10-4*i); len(got) != want {
+			t.Errorf("Page %d: expected %d rows, got %d", i, want, len(got))
+		}
+		all = append(all, got...)
+	}
+	if !reflect.DeepEqual(all, tasks) {
+		t.Errorf("Pages round trip:\n got %+v\nwant %+v", all, tasks)
+	}
+
+	if _, err := MarshalPaged(tasks, 0); err == nil {
+		t.Error("Expected an error for 0 rows per page")
+	}
+	if _, err := MarshalPaged(tasks[0], 4); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("Expected ErrUnsupportedType for a struct, got %v", err)
+	}
+}
+
+func TestQuotedStrings(t *testing.T) {
+	type Entry struct {
+		Key   string    `zoon:"key"`
+		Note  string    `zoon:"note"`
+		Level string    `zoon:"level"`
+		At    time.Time `zoon:"at"`
+	}
+	at := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	data := []Entry{
+		{"hello_world", "say \"hi\"", "info", at},
+		{"hello world", "", "info", at.Add(time.Hour)},
+		{"plain", "a_b c", "warn", at},
+	}
+
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf, WithQuotedStrings(true)).Encode(data); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{`"hello_world"`, `"hello world"`, `"say \"hi\""`, " level:s"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %s in output:\n%s", want, out)
+		}
+	}
+
+	var got []Entry
+	if err := Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, data) {
+		t.Errorf("Quoted strings round trip:\n got %+v\nwant %+v", got, data)
+	}
+}
+
+func TestInterfaceNumberTypes(t *testing.T) {
+	var m map[string]any
+	if err := Unmarshal([]byte("count:3 neg:-2 ratio:0.75 whole:2.0 exp:1e3 big:18446744073709551615 code=007 name=x"), &m); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"count": int64(3),
+		"neg":   int64(-2),
+		"ratio": 0.75,
+		"whole": 2.0,
+		"exp":   1000.0,
+		"big":   uint64(18446744073709551615),
+		"code":  "007",
+		"name":  "x",
+	}
+	for k, v := range want {
+		if reflect.TypeOf(m[k]) != reflect.TypeOf(v) || m[k] != v {
+			t.Errorf("%s: got %T %v, want %T %v", k, m[k], m[k], v, v)
+		}
+	}
+}
+
+func TestCRLFInput(t *testing.T) {
+	type User struct {
+		ID   int    `zoon:"id"`
+		Name string `zoon:"name"`
+		Role string `zoon:"role"`
+	}
+	users := []User{{1, "Ada Lovelace", "Admin"}, {2, "Alan", "User"}, {3, "Grace", "Admin"}}
+	enc, err := Marshal(users)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := strings.ReplaceAll("%u=users\n"+string(enc)+"---\nhost=localhost port:8080\n", "\n", "\r\n")
+
+	dec := NewDecoder(strings.NewReader(input))
+	var got []User
+	if err := dec.Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, users) {
+		t.Errorf("CRLF table:\n got %+v\nwant %+v", got, users)
+	}
+	var cfg map[string]any
+	if err := dec.Decode(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg["host"] != "localhost" || cfg["port"] != int64(8080) {
+		t.Errorf("CRLF inline object: %v", cfg)
+	}
+
+	sc := NewDecoder(strings.NewReader(strings.ReplaceAll(string(enc), "\n", "\r\n"))).Scanner(&User{})
+	var scanned []User
+	for sc.Scan() {
+		scanned = append(scanned, *sc.Row().(*User))
+	}
+	if sc.Err() != nil || !reflect.DeepEqual(scanned, users) {
+		t.Errorf("CRLF scanner: %v %+v", sc.Err(), scanned)
+	}
+}
+
+func TestObjectListFields(t *testing.T) {
+	type Tag struct {
+		Key   string `zoon:"key"`
+		Value int    `zoon:"value"`
+	}
+	type Post struct {
+		Title  string `zoon:"title"`
+		Tags   []Tag  `zoon:"tags"`
+		Pinned *[]Tag `zoon:"pinned"`
+	}
+	posts := []Post{
+		{"first post", []Tag{{"lang go", 1}, {"level", 2}}, &[]Tag{{"top", 9}}},
+		{"second", nil, nil},
+	}
+
+	enc, err := Marshal(posts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(enc), "[{key=lang_go value:1} {key=level value:2}]") {
+		t.Errorf("Expected a list of inline objects, got:\n%s", enc)
+	}
+	var got []Post
+	if err := Unmarshal(enc, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, posts) {
+		t.Errorf("Object lists roundtrip:\n got %+v\nwant %+v", got, posts)
+	}
+
+	var rows []map[string]any
+	if err := Unmarshal(enc, &rows); err != nil {
+		t.Fatal(err)
+	}
+	want := []any{map[string]any{"key": "lang go", "value": int64(1)}, map[string]any{"key": "level", "value": int64(2)}}
+	if !reflect.DeepEqual(rows[0]["tags"], want) {
+		t.Errorf("Object list into a map: got %#v want %#v", rows[0]["tags"], want)
+	}
+}
+
+func TestMarshalIndent(t *testing.T) {
+	type Player struct {
+		ID    int     `zoon:"id"`
+		Name  string  `zoon:"name"`
+		Team  string  `zoon:"team"`
+		Score float64 `zoon:"score"`
+		Club  string  `zoon:"club"`
+	}
+	players := []Player{
+		{1, "Ada Lovelace", "red", 9.5, "chess"},
+		{2, "Al", "blue", 10.25, "chess"},
+		{3, "Grace", "red", 7, "chess"},
+	}
+
+	enc, err := MarshalIndent(players)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# @club=chess id:i+ name:s       score:f team=blue|red\n" +
+		"                    Ada_Lovelace 9.5     red\n" +
+		"                    Al           10.25   blue\n" +
+		"                    Grace        7.0     red\n"
+	if string(enc) != want {
+		t.Errorf("Aligned table:\n got %q\nwant %q", enc, want)
+	}
+
+	var got []Player
+	if err := Unmarshal(enc, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, players) {
+		t.Errorf("Aligned roundtrip:\n got %+v\nwant %+v", got, players)
+	}
+}
+
+func TestIndexedEnumMinSaving(t *testing.T) {
+	type Row struct {
+		Kind string `zoon:"kind"`
+	}
+	var rows []Row
+	for i := range 12 {
+		rows = append(rows, Row{[]string{"category-alpha", "category-beta", "category-gamma"}[i%3]})
+	}
+	header := func(opts ...EncoderOption) string {
+		var buf bytes.Buffer
+		if err := NewEncoder(&buf, opts...).Encode(rows); err != nil {
+			t.Fatal(err)
+		}
+		var dec []Row
+		if err := Unmarshal(buf.Bytes(), &dec); err != nil || !reflect.DeepEqual(dec, rows) {
+			t.Errorf("Roundtrip failed: %v", err)
+		}
+		return strings.SplitN(buf.String(), "\n", 2)[0]
+	}
+
+	// Indexes save 89 of 156 bytes, about 57%.
+	indexed, literal := "# kind!category-alpha|category-beta|category-gamma", "# kind=category-alpha|category-beta|category-gamma"
+	if got := header(); got != indexed {
+		t.Errorf("Default saving: got %q", got)
+	}
+	if got := header(WithIndexedEnumMinSaving(0.5)); got != indexed {
+		t.Errorf("50%% saving required: got %q", got)
+	}
+	if got := header(WithIndexedEnumMinSaving(0.9)); got != literal {
+		t.Errorf("90%% saving required: got %q", got)
+	}
+}
+
+func TestLiteralTildeValues(t *testing.T) {
+	type Row struct {
+		Path  string   `zoon:"path"`
+		Mark  string   `zoon:"mark"`
+		Owner *string  `zoon:"owner"`
+		Alts  []string `zoon:"alts"`
+	}
+	tilde := "~"
+	rows := []Row{
+		{"~", "~", &tilde, []string{"~", "home"}},
+		{"~/src", "~", nil, nil},
+		{"/tmp", "x", &tilde, []string{"~"}},
+	}
+	for _, opts := range [][]Option{nil, {WithNullSymbol("null")}} {
+		var buf bytes.Buffer
+		encOpts := make([]EncoderOption, len(opts))
+		decOpts := make([]DecoderOption, len(opts))
+		for i, o := range opts {
+			encOpts[i], decOpts[i] = o, o
+		}
+		if err := NewEncoder(&buf, encOpts...).Encode(rows); err != nil {
+			t.Fatal(err)
+		}
+		out := buf.String()
+		var got []Row
+		if err := NewDecoder(&buf, decOpts...).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, rows) {
+			t.Errorf("Literal ~ roundtrip with %d options:\n got %+v\nwant %+v\n%s", len(opts), got, rows, out)
+		}
+	}
+
+	enc, err := Marshal(Row{Path: "~", Mark: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var inline Row
+	if err := Unmarshal(enc, &inline); err != nil || inline.Path != "~" || inline.Owner != nil {
+		t.Errorf("Literal ~ inline: %v %+v from %s", err, inline, enc)
+	}
+}
+
+func TestParseSchema(t *testing.T) {
+	lines := []string{
+		"%a=address",
+		"# @%a.country=FR @v:i=2 @beta:y id:i+ %a.city:s role!Admin|User score:f tags:s[] +3",
+		"ignored row",
+	}
+	s, err := ParseSchema(lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Schema{
+		Aliases: map[string]string{"a": "address"},
+		Constants: []ColumnDef{
+			{Name: "address.country", TypeCode: "s", IsConstant: true, Value: "FR"},
+			{Name: "v", TypeCode: "i", IsConstant: true, Value: "2"},
+			{Name: "beta", IsConstant: true, Value: "y"},
+		},
+		Columns: []ColumnDef{
+			{Name: "id", TypeCode: "i", IsAutoInc: true},
+			{Name: "address.city", TypeCode: "s"},
+			{Name: "role", TypeCode: "s", Indexed: true, Options: []string{"Admin", "User"}},
+			{Name: "score", TypeCode: "f"},
+			{Name: "tags", TypeCode: "s[]"},
+		},
+		RowCount: 3,
+	}
+	if !reflect.DeepEqual(s, want) {
+		t.Fatalf("ParseSchema:\n got %+v\nwant %+v", s, want)
+	}
+	if got := s.String(); got != strings.Join(lines[:2], "\n") {
+		t.Errorf("String:\n got %q\nwant %q", got, strings.Join(lines[:2], "\n"))
+	}
+
+	for _, tc := range []struct {
+		row []string
+		ok  bool
+	}{
+		{[]string{"Paris", "1", "9.5", "[a b]"}, true},
+		{[]string{"~", "0", "~", "~"}, true},
+		{[]string{"Paris", "1", "9.5"}, false},
+		{[]string{"Paris", "2", "9.5", "[a]"}, false},
+		{[]string{"Paris", "0", "high", "[a]"}, false},
+		{[]string{"Paris", "0", "1", "a"}, false},
+	} {
+		if err := s.Validate(tc.row); (err == nil) != tc.ok {
+			t.Errorf("Validate(%q) = %v, want ok %v", tc.row, err, tc.ok)
+		} else if err != nil && !errors.Is(err, ErrInvalidFormat) {
+			t.Errorf("Validate(%q) = %v, want ErrInvalidFormat", tc.row, err)
+		}
+	}
+
+	if _, err := ParseSchema([]string{"%a=x"}); err == nil {
+		t.Error("Expected an error for lines without a header")
+	}
+
+	type Tick struct {
+		TS   *int64 `zoon:"ts"`
+		Name string `zoon:"name"`
+	}
+	ts := func(n int64) *int64 { return &n }
+	ticks := []Tick{{ts(1000), "a"}, {ts(1010), "b"}, {nil, "c"}, {ts(1005), "d"}}
+	delta, err := ParseSchema([]string{"# ts:i^ name:s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, err := MarshalWith(ticks, delta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "# ts:i^ name:s\n1000 a\n10 b\n~ c\n-5 d\n"; string(enc) != want {
+		t.Errorf("Pinned delta column:\n got %q\nwant %q", enc, want)
+	}
+	var back []Tick
+	if err := Unmarshal(enc, &back); err != nil || !reflect.DeepEqual(back, ticks) {
+		t.Errorf("Pinned delta roundtrip: %v %+v", err, back)
+	}
+	if _, err := MarshalWith([]map[string]any{{"ts": "x"}}, delta); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("Expected ErrInvalidFormat for a non-integer delta cell, got %v", err)
+	}
+}
+
+func TestAutoIncStart(t *testing.T) {
+	type Item struct {
+		ID   int    `zoon:"id"`
+		Name string `zoon:"name"`
+	}
+	for _, tc := range []struct {
+		start int
+		code  string
+	}{
+		{0, "id:i+0"},
+		{100, "id:i+100"},
+		{-2, "id:i+-2"},
+		{1, "id:i+ "},
+	} {
+		items := []Item{{tc.start, "a"}, {tc.start + 1, "b"}, {tc.start + 2, "c"}}
+		enc, err := Marshal(items)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(enc), tc.code) {
+			t.Errorf("Start %d: expected %s in\n%s", tc.start, tc.code, enc)
+		}
+		var got []Item
+		if err := Unmarshal(enc, &got); err != nil || !reflect.DeepEqual(got, items) {
+			t.Errorf("Start %d roundtrip: %v\n got %+v\nwant %+v", tc.start, err, got, items)
+		}
+	}
+
+	// Gaps are written out.
+	enc, err := Marshal([]Item{{0, "a"}, {2, "b"}})
+	if err != nil || !strings.HasPrefix(string(enc), "# id:i name:s\n") {
+		t.Errorf("Gapped ids: %v\n%s", err, enc)
+	}
+
+	var ids []struct {
+		ID int `zoon:"id"`
+	}
+	if err := Unmarshal([]byte("# id:i+100 +3"), &ids); err != nil || len(ids) != 3 || ids[2].ID != 102 {
+		t.Errorf("Implicit rows from 100: %v %+v", err, ids)
+	}
+
+	s, err := ParseSchema([]string{"# id:i+0 name:s"})
+	if err != nil || !s.Columns[0].IsAutoInc || s.Columns[0].Value != "0" || s.String() != "# id:i+0 name:s" {
+		t.Errorf("Schema of a 0-based column: %v %+v", err, s)
+	}
+}
+
+func TestTranscodeToJSONTypes(t *testing.T) {
+	in := "// export\n%a=addr\n# @region=eu @v:i=2 id:i+ code:s %a.city:s %a.zip:s ok:b score:f role!admin|user\n" +
+		"007 Paris 75001 1 9.5 0\n" +
+		"42 ~ ~ 0 ~ 1\n"
+
+	var jb bytes.Buffer
+	if err := TranscodeToJSON(strings.NewReader(in), &jb); err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"addr":{"city":"Paris","zip":"75001"},"code":"007","id":1,"ok":true,"region":"eu","role":"admin","score":9.5,"v":2},` +
+		`{"addr":{"city":null,"zip":null},"code":"42","id":2,"ok":false,"region":"eu","role":"user","score":null,"v":2}]` + "\n"
+	if jb.String() != want {
+		t.Errorf("Typed JSON:\n got %s\nwant %s", jb.String(), want)
+	}
+
+	var rows []map[string]any
+	if err := json.Unmarshal(jb.Bytes(), &rows); err != nil || len(rows) != 2 {
+		t.Errorf("Output is not a JSON array of objects: %v", err)
+	}
+
+	jb.Reset()
+	if err := TranscodeToJSON(strings.NewReader("# id:i+ name:s\n"), &jb); err != nil || jb.String() != "[]\n" {
+		t.Errorf("Empty table to JSON: %q %v", jb.String(), err)
+	}
+
+	jb.Reset()
+	in = "# @grace:dur=30s wait:dur at:time epoch:unix key:h waits:dur[]\n" +
+		"1h30m0s 2024-01-02T03:04:05Z 1700000000 6869 [1s 2m]\n"
+	if err := TranscodeToJSON(strings.NewReader(in), &jb); err != nil {
+		t.Fatal(err)
+	}
+	want = `[{"at":"2024-01-02T03:04:05Z","epoch":1700000000,"grace":"30s","key":"6869","wait":"1h30m0s","waits":["1s","2m"]}]` + "\n"
+	if jb.String() != want {
+		t.Errorf("Text-like JSON values:\n got %s\nwant %s", jb.String(), want)
+	}
+}
+
+func TestBracketListDecode(t *testing.T) {
+	type Post struct {
+		Title string   `zoon:"title"`
+		Tags  []string `zoon:"tags"`
+		IDs   []int    `zoon:"ids"`
+	}
+	post := Post{"hello", []string{"go", "hello world", "zoon"}, []int{3, -1, 42}}
+
+	enc, err := Marshal(post)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Post
+	if err := Unmarshal(enc, &got); err != nil || !reflect.DeepEqual(got, post) {
+		t.Errorf("Inline list roundtrip for %s: %v\n got %+v\nwant %+v", enc, err, got, post)
+	}
+
+	if err := Unmarshal([]byte(`title=x tags:[alice "bob smith" carol] ids:[1 2 3]`), &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Tags, []string{"alice", "bob smith", "carol"}) || !reflect.DeepEqual(got.IDs, []int{1, 2, 3}) {
+		t.Errorf("Bracket literals: %+v", got)
+	}
+
+	var rows []Post
+	if err := Unmarshal([]byte("# title:s tags:s[] ids:i[]\na [x y] [1 2]\nb [] ~\n"), &rows); err != nil {
+		t.Fatal(err)
+	}
+	want := []Post{{"a", []string{"x", "y"}, []int{1, 2}}, {"b", []string{}, nil}}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("Table lists:\n got %#v\nwant %#v", rows, want)
+	}
+
+	if err := Unmarshal([]byte("title=x ids:[1 two]"), &got); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("Expected ErrInvalidFormat for a non-integer item, got %v", err)
+	}
+}
+
+func TestBracketListEncode(t *testing.T) {
+	type Doc struct {
+		Tags []string `zoon:"tags"`
+		IDs  []int    `zoon:"ids"`
+		Raw  []byte   `zoon:"raw"`
+	}
+	doc := Doc{[]string{"hello world", "foo", "bar"}, []int{1, 2, 3}, []byte("hi")}
+	for _, tc := range []struct {
+		opts []EncoderOption
+		want string
+	}{
+		{nil, "tags:[hello_world foo bar] ids:[1 2 3] raw:aGk="},
+		{[]EncoderOption{WithSpaceEscaping(false)}, `tags:["hello world" foo bar] ids:[1 2 3] raw:aGk=`},
+	} {
+		var buf bytes.Buffer
+		if err := NewEncoder(&buf, tc.opts...).Encode(doc); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != tc.want {
+			t.Errorf("Inline lists:\n got %s\nwant %s", buf.String(), tc.want)
+		}
+	}
+
+	enc, err := Marshal([]Doc{doc, {}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(enc), "# ids:i[] raw:base64 tags:s[]\n") {
+		t.Errorf("List column types: %s", enc)
+	}
+
+	for _, tc := range []struct {
+		v    any
+		want bool
+	}{
+		{[]string{}, true},
+		{[3]int{}, true},
+		{[]float32{}, true},
+		{[]bool{}, true},
+		{[]byte{}, false},
+		{[]time.Duration{}, false},
+		{[]Money{}, false},
+		{[]map[string]any{}, false},
+		{[]any{}, false},
+		{"abc", false},
+	} {
+		if got := isSlicePrimitive(reflect.TypeOf(tc.v)); got != tc.want {
+			t.Errorf("isSlicePrimitive(%T) = %v, want %v", tc.v, got, tc.want)
+		}
+	}
+}
+
+func TestEnumValidation(t *testing.T) {
+	type Task struct {
+		Name   string `zoon:"name"`
+		Status string `zoon:"status"`
+	}
+	valid := "# name:s status=done|open\nwrite done\nreview open\n"
+	var tasks []Task
+	if err := NewDecoder(strings.NewReader(valid), WithEnumValidation(true)).Decode(&tasks); err != nil || tasks[1].Status != "open" {
+		t.Errorf("Valid enum tokens: %v %+v", err, tasks)
+	}
+
+	invalid := "# name:s status=done|open\nwrite done\nreview opne\n"
+	err := NewDecoder(strings.NewReader(invalid), WithEnumValidation(true)).Decode(&tasks)
+	if !errors.Is(err, ErrInvalidFormat) || !strings.Contains(err.Error(), `"opne"`) || !strings.Contains(err.Error(), "row 2") {
+		t.Errorf("Expected an invalid enum error in row 2, got %v", err)
+	}
+
+	// Without validation, and outside strict mode, the token passes through.
+	if err := Unmarshal([]byte(invalid), &tasks); err != nil || tasks[1].Status != "opne" {
+		t.Errorf("Unvalidated enum: %v %+v", err, tasks)
+	}
+}
+
+func TestFloatPrecision(t *testing.T) {
+	type Reading struct {
+		Sensor string  `zoon:"sensor"`
+		Small  float32 `zoon:"small"`
+		Sum    float64 `zoon:"sum"`
+	}
+	tenth, fifth := 0.1, 0.2
+	readings := []Reading{{"a", 0.1, tenth + fifth}, {"b", 3.3, 2}}
+
+	enc, err := Marshal(readings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// float32 values keep their own shortest form, not float64 widening's
+	// 0.10000000149011612.
+	if want := "# sensor:s small:f sum:f\na 0.1 0.30000000000000004\nb 3.3 2.0\n"; string(enc) != want {
+		t.Errorf("Shortest floats:\n got %q\nwant %q", enc, want)
+	}
+
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf, WithFloatPrecision(2)).Encode(readings); err != nil {
+		t.Fatal(err)
+	}
+	if want := "# sensor:s small:f2 sum:f2\na 0.10 0.30\nb 3.30 2.00\n"; buf.String() != want {
+		t.Errorf("Two decimal places:\n got %q\nwant %q", buf.String(), want)
+	}
+	var got []Reading
+	if err := Unmarshal(buf.Bytes(), &got); err != nil || got[0].Sum != 0.3 || got[1].Small != 3.3 {
+		t.Errorf("Fixed precision roundtrip: %v %+v", err, got)
+	}
+
+	buf.Reset()
+	if err := NewEncoder(&buf, WithFloatPrecision(3)).Encode(map[string]any{"x": float32(1.0 / 3)}); err != nil || buf.String() != "x:0.333" {
+		t.Errorf("Inline precision: %q %v", buf.String(), err)
+	}
+
+	buf.Reset()
+	if err := NewEncoder(&buf, WithFloatPrecision(0)).Encode(readings); err != nil {
+		t.Fatal(err)
+	}
+	if want := "# sensor:s small:f0 sum:f0\na 0 0\nb 3 2\n"; buf.String() != want {
+		t.Errorf("No decimal places:\n got %q\nwant %q", buf.String(), want)
+	}
+	var whole []map[string]any
+	if err := Unmarshal(buf.Bytes(), &whole); err != nil || whole[1]["sum"] != 2.0 {
+		t.Errorf("f0 cells decode as floats: %v %#v", err, whole)
+	}
+}
+
+func TestIntFieldWidths(t *testing.T) {
+	type count int32
+	type Row struct {
+		A int32  `zoon:"a"`
+		B uint32 `zoon:"b"`
+		C count  `zoon:"c"`
+		D int64  `zoon:"d"`
+	}
+	var rows []Row
+	if err := Unmarshal([]byte("# a:i b:u c:i d:i\n2147483647 4294967295 -2147483648 4294967296\n"), &rows); err != nil {
+		t.Fatal(err)
+	}
+	if want := (Row{math.MaxInt32, math.MaxUint32, math.MinInt32, 1 << 32}); rows[0] != want {
+		t.Errorf("Widths at their limits: got %+v want %+v", rows[0], want)
+	}
+
+	for _, data := range []string{
+		"# a:i b:u c:i d:i\n2147483648 0 0 0\n",
+		"# a:i b:u c:i d:i\n0 4294967296 0 0\n",
+		"# a:i b:u c:i d:i\n0 0 -2147483649 0\n",
+		"# a:i b:u c:i d:i\n0 -1 0 0\n",
+		"a:2147483648",
+	} {
+		var row Row
+		dest := any(&rows)
+		if !strings.HasPrefix(data, "#") {
+			dest = &row
+		}
+		if err := Unmarshal([]byte(data), dest); !errors.Is(err, ErrInvalidFormat) {
+			t.Errorf("Expected ErrInvalidFormat for %q, got %v", data, err)
+		}
+	}
+}
+
+func TestRequiredFields(t *testing.T) {
+	type Address struct {
+		City string `zoon:"city,required"`
+		Zip  string `zoon:"zip"`
+	}
+	type User struct {
+		ID      int     `zoon:"id,required"`
+		Name    string  `zoon:"name,required"`
+		Role    string  `zoon:"role"`
+		Address Address `zoon:"addr"`
+	}
+
+	complete := "# id:i name:s role:s addr.city:s\n1 Alice ~ Paris\n2 Bob admin Lyon\n"
+	var users []User
+	if err := NewDecoder(strings.NewReader(complete), WithRequiredFields(true)).Decode(&users); err != nil {
+		t.Fatalf("Complete rows rejected: %v", err)
+	}
+
+	for _, tc := range []struct{ data, msg string }{
+		{"# id:i name:s addr.city:s\n1 Alice Paris\n2 ~ Lyon\n", "missing required fields name in row 2"},
+		{"# id:i addr.city:s\n1 Paris\n", "missing required fields name in row 1"},
+		{"# id:i name:s\n1 Alice\n", "missing required fields addr.city in row 1"},
+	} {
+		err := NewDecoder(strings.NewReader(tc.data), WithRequiredFields(true)).Decode(&users)
+		if !errors.Is(err, ErrInvalidFormat) || !strings.Contains(err.Error(), tc.msg) {
+			t.Errorf("Expected %q, got %v", tc.msg, err)
+		}
+	}
+
+	var u User
+	err := NewDecoder(strings.NewReader("name=Alice role=admin"), WithStrictFields(true)).Decode(&u)
+	if !errors.Is(err, ErrInvalidFormat) || !strings.Contains(err.Error(), "missing required fields id, addr.city") {
+		t.Errorf("Expected missing id and addr.city under strict fields, got %v", err)
+	}
+
+	// Without either option, missing fields stay zero.
+	if err := Unmarshal([]byte("# id:i\n1\n"), &users); err != nil || users[0].Name != "" {
+		t.Errorf("Lenient decode: %v %+v", err, users)
+	}
+}
+
+func TestEmbeddedUntagged(t *testing.T) {
+	type Base struct {
+		ID   int
+		Name string
+	}
+	type T struct {
+		Base
+		Extra string
+	}
+	rows := []T{{Base{5, "a"}, "x"}, {Base{9, "b b"}, "y"}}
+
+	enc, err := Marshal(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(enc), "# Extra:s ID:i Name:s\n") {
+		t.Errorf("Promoted columns: %s", enc)
+	}
+	var got []T
+	if err := Unmarshal(enc, &got); err != nil || !reflect.DeepEqual(got, rows) {
+		t.Errorf("Embedded roundtrip: %v\n got %+v\nwant %+v", err, got, rows)
+	}
+
+	inline, err := Marshal(rows[1])
+	if err != nil || string(inline) != "ID:9 Name=b_b Extra=y" {
+		t.Errorf("Promoted inline keys: %s %v", inline, err)
+	}
+	var one T
+	if err := Unmarshal(inline, &one); err != nil || one != rows[1] {
+		t.Errorf("Embedded inline roundtrip: %v %+v", err, one)
+	}
+}
+
+func TestUnexportedFields(t *testing.T) {
+	type T struct {
+		mu     sync.Mutex
+		secret string
+		Name   string
+		count  int
+	}
+	rows := []*T{{secret: "x", Name: "a", count: 1}, {Name: "b"}}
+
+	enc, err := Marshal(rows)
+	if err != nil || string(enc) != "# Name:s\na\nb\n" {
+		t.Errorf("Unexported columns: %q %v", enc, err)
+	}
+	inline, err := Marshal(rows[0])
+	if err != nil || string(inline) != "Name=a" {
+		t.Errorf("Unexported inline keys: %q %v", inline, err)
+	}
+
+	var got T
+	if err := Unmarshal([]byte("Name=a secret=y"), &got); err != nil || got.Name != "a" || got.secret != "" {
+		t.Errorf("Unexported field set: %v %q", err, got.secret)
+	}
+	dec := NewDecoder(strings.NewReader("Name=a secret=y"), WithStrictFields(true))
+	if err := dec.Decode(&T{}); err == nil || !strings.Contains(err.Error(), "secret") {
+		t.Errorf("Unexported field under WithStrictFields: %v", err)
+	}
+}
+
+func TestStringTagOption(t *testing.T) {
+	type T struct {
+		Count int      `json:"count,string"`
+		On    bool     `json:"on,string"`
+		Ratio *float64 `json:"ratio,string"`
+	}
+	r := 1.5
+	rows := []T{{5, true, &r}, {12, false, nil}}
+
+	enc, err := Marshal(rows)
+	if err != nil || string(enc) != "# count:s on:s ratio:s\n5 true 1.5\n12 false ~\n" {
+		t.Errorf("String columns: %q %v", enc, err)
+	}
+	var got []T
+	if err := Unmarshal(enc, &got); err != nil || len(got) != 2 || got[1].Count != 12 || !got[0].On || *got[0].Ratio != 1.5 || got[1].Ratio != nil {
+		t.Errorf("String columns roundtrip: %v %+v", err, got)
+	}
+
+	inline, err := Marshal(rows[0])
+	if err != nil || string(inline) != "count=5 on=true ratio=1.5" {
+		t.Errorf("String inline values: %q %v", inline, err)
+	}
+	var one T
+	if err := Unmarshal([]byte(`count="7" on=false`), &one); err != nil || one.Count != 7 || one.On {
+		t.Errorf("Quoted number into string-tagged field: %v %+v", err, one)
+	}
+}
+
+func TestDefaultTag(t *testing.T) {
+	type DB struct {
+		Host string `zoon:"host,default:localhost"`
+		Port int    `zoon:"port,default:5432"`
+	}
+	type Config struct {
+		Name       string  `zoon:"name"`
+		MaxRetries int     `zoon:"max_retries,default:3"`
+		Ratio      float64 `zoon:"ratio,default:0.5"`
+		Debug      bool    `zoon:"debug,default:y"`
+		DB         DB      `zoon:"db"`
+	}
+
+	var rows []Config
+	if err := Unmarshal([]byte("# name:s max_retries:i db.port:i\na 5 1\nb ~ 2\n"), &rows); err != nil {
+		t.Fatal(err)
+	}
+	want := []Config{
+		{"a", 5, 0.5, true, DB{"localhost", 1}},
+		{"b", 0, 0.5, true, DB{"localhost", 2}},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("Tabular defaults:\n got %+v\nwant %+v", rows, want)
+	}
+
+	rows = nil
+	if err := Unmarshal([]byte("# @debug:n name:s\na\n"), &rows); err != nil || len(rows) != 1 || rows[0].Debug || rows[0].MaxRetries != 3 {
+		t.Errorf("Constant over default: %v %+v", err, rows)
+	}
+
+	var cfg Config
+	if err := Unmarshal([]byte("name=c ratio:2"), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if want := (Config{"c", 3, 2, true, DB{"localhost", 5432}}); cfg != want {
+		t.Errorf("Inline defaults: got %+v, want %+v", cfg, want)
+	}
+
+	type Bad struct {
+		N int `zoon:"n,default:many"`
+	}
+	var bad Bad
+	if err := Unmarshal([]byte("x:1"), &bad); err == nil {
+		t.Error("Expected error for a default that does not fit its field")
+	}
+}
+
+func TestValid(t *testing.T) {
+	rows, _ := Marshal([]User{{1, "Alice Smith", "Admin", true}, {2, "Bob", "User", false}})
+	tests := []struct {
+		name string
+		data string
+		want bool
+	}{
+		{"inline", `host=localhost port:3000 tags=[a b] db={user=x}`, true},
+		{"tabular", string(rows), true},
+		{"tabular with aliases and metadata", "%u=user\nsource:api\n# %u.id:i %u.name:s\n1 \"a b\"\n", true},
+		{"row count", "# id:i+ +3", true},
+		{"object rows", "{id:1 name=a}\n{id:2 name=b}", true},
+		{"documents", "a:1\n---\n# x:i\n1\n", true},
+		{"empty", "", false},
+		{"comment only", "// nothing", false},
+		{"unbalanced inline brace", "db={user=x", false},
+		{"unbalanced cell brace", "# tags:s[]\n[a b\n", false},
+		{"stray closing brace", "# n:s\na}\n", false},
+		{"unterminated quote", "# n:s\n\"a b\n", false},
+		{"unbalanced object row", "{id:1 name=a\n", false},
+		{"missing header", "Alice Admin 1\nBob User 0", false},
+		{"bad second document", "a:1\n---\nb={", false},
+	}
+	for _, tt := range tests {
+		if got := Valid([]byte(tt.data)); got != tt.want {
+			t.Errorf("Valid(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCompact(t *testing.T) {
+	type Player struct {
+		ID   int    `zoon:"id"`
+		Name string `zoon:"name"`
+		Team string `zoon:"team"`
+		Note string `zoon:"note"`
+	}
+	players := []Player{{1, "Ada Lovelace", "red", "x"}, {2, "Al", "blue", "two  spaces"}}
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf, WithAlignedColumns(true), WithSpaceEscaping(false)).Encode(players); err != nil {
+		t.Fatal(err)
+	}
+	padded := buf.Bytes()
+	plain, err := Marshal(players)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var dst bytes.Buffer
+	if err := Compact(&dst, padded); err != nil {
+		t.Fatal(err)
+	}
+	var got []Player
+	if err := Unmarshal(dst.Bytes(), &got); err != nil || !reflect.DeepEqual(got, players) {
+		t.Errorf("Compact roundtrip: %v %+v\n%s", err, got, dst.Bytes())
+	}
+	if !strings.Contains(dst.String(), `"two  spaces"`) || strings.Contains(dst.String(), "  \"") {
+		t.Errorf("Compact kept padding or lost quoted spaces:\n%s", dst.Bytes())
+	}
+
+	dst.Reset()
+	indented, _ := MarshalIndent(players)
+	if err := Compact(&dst, indented); err != nil || dst.String() != string(plain) {
+		t.Errorf("Compact(MarshalIndent):\n got %q\nwant %q (%v)", dst.Bytes(), plain, err)
+	}
+
+	dst.Reset()
+	src := "  // config\nhost=localhost    port:3000   tags=[a b]\n\n---\n\n\n%p=player\n#   %p.id:i   %p.name:s\n  1    a\n\n  2    \"b  c\"\n"
+	want := "// config\nhost=localhost port:3000 tags=[a b]\n---\n%p=player\n# %p.id:i %p.name:s\n1 a\n2 \"b  c\"\n"
+	if err := Compact(&dst, []byte(src)); err != nil || dst.String() != want {
+		t.Errorf("Compact documents:\n got %q\nwant %q (%v)", dst.Bytes(), want, err)
+	}
+
+	dst.Reset()
+	dst.WriteString("keep")
+	if err := Compact(&dst, []byte("# n:s\n\"open\n")); err == nil || dst.String() != "keep" {
+		t.Errorf("Compact error: %v, dst %q", err, dst.Bytes())
+	}
+}
+
+func TestDeterministicOutput(t *testing.T) {
+	// Prefixes of equal length and count tie on alias savings, and
+	// account.owner nests inside account.
+	var rows []map[string]any
+	for i := range int64(4) {
+		rows = append(rows, map[string]any{
+			"account": map[string]any{"id": i, "owner": map[string]any{"name": fmt.Sprint("n", i), "mail": fmt.Sprint("m", i)}},
+			"billing": map[string]any{"id": i * 2, "plan": fmt.Sprint("p", i%3)},
+			"contact": map[string]any{"id": i * 3, "mail": fmt.Sprint("c", i)},
+		})
+	}
+	encode := func() string {
+		var buf bytes.Buffer
+		if err := NewEncoder(&buf, WithAliases(true)).Encode(rows); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+
+	first := encode()
+	if !strings.HasPrefix(first, "%") {
+		t.Fatalf("Expected aliases:\n%s", first)
+	}
+	for i := range 100 {
+		if got := encode(); got != first {
+			t.Fatalf("Run %d differs:\n got %s\nwant %s", i, got, first)
+		}
+	}
+
+	var back []map[string]any
+	if err := Unmarshal([]byte(first), &back); err != nil || !reflect.DeepEqual(back, rows) {
+		t.Errorf("Aliased roundtrip: %v\n got %v\nwant %v", err, back, rows)
+	}
+}
+
+func TestSeparatorLikeValues(t *testing.T) {
+	list := []string{"---", "x", "y"}
+	enc, err := Marshal(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gotList []string
+	if err := Unmarshal(enc, &gotList); err != nil || !reflect.DeepEqual(gotList, list) {
+		t.Errorf("--- list cell: %v %q\n%s", err, gotList, enc)
+	}
+
+	type R struct {
+		ID   int    `zoon:"id"`
+		Mark string `zoon:"mark"`
+	}
+	rows := []R{{1, "---"}, {2, "x"}, {3, "---"}}
+	enc, err = Marshal(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gotRows []R
+	if err := Unmarshal(enc, &gotRows); err != nil || !reflect.DeepEqual(gotRows, rows) {
+		t.Errorf("--- struct column: %v %+v\n%s", err, gotRows, enc)
+	}
+}